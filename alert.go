@@ -31,7 +31,7 @@ func ping(transRefNum []byte, user string) []byte {
 
 // sendAlert writes an alert packet to the socket every alertInterval.
 func sendAlert(transRefNum []byte, user string, writer *bufio.Writer, wg *sync.WaitGroup,
-	closeChan chan struct{}, alertInterval time.Duration, mu *sync.Mutex, logger Logger) {
+	closeChan chan struct{}, alertInterval time.Duration, mu *sync.Mutex, hook FrameHook, logger Logger) {
 	wg.Add(1)
 	ticker := time.NewTicker(alertInterval)
 	go func() {
@@ -45,11 +45,14 @@ func sendAlert(transRefNum []byte, user string, writer *bufio.Writer, wg *sync.W
 				return
 			case <-ticker.C:
 				mu.Lock()
-				if _, err := writer.Write(ping(transRefNum, user)); err != nil {
+				pingPacket := ping(transRefNum, user)
+				if _, err := writer.Write(pingPacket); err != nil {
 					logger.Printf("error writing ping: %v\n", err)
 				}
 				if err := writer.Flush(); err != nil {
 					logger.Printf("error flushing ping: %v\n", err)
+				} else {
+					hook.trace(Outbound, pingPacket)
 				}
 				mu.Unlock()
 			}
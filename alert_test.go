@@ -33,7 +33,7 @@ func TestSendAlert(t *testing.T) {
 	wg := new(sync.WaitGroup)
 	closeChan := make(chan struct{}, 1)
 	mu := new(sync.Mutex)
-	sendAlert([]byte("01"), "emi_client", writer, wg, closeChan, 500*time.Millisecond, mu,
+	sendAlert([]byte("01"), "emi_client", writer, wg, closeChan, 500*time.Millisecond, mu, nil,
 		&Client{logger: log.New(os.Stdout, "debug ", 0)})
 	runtime.Gosched()
 	time.Sleep(700 * time.Millisecond)
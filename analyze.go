@@ -0,0 +1,114 @@
+package ucp
+
+import (
+	"strconv"
+
+	"github.com/go-gsm/charset"
+)
+
+// Encoding is the character encoding of a text message.
+type Encoding int
+
+const (
+	// EncodingGSM7 is the GSM 7-bit default alphabet.
+	EncodingGSM7 Encoding = iota
+	// EncodingUCS2 is UCS-2, sent as UTF-16.
+	EncodingUCS2
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingGSM7:
+		return "GSM-7"
+	case EncodingUCS2:
+		return "UCS-2"
+	}
+	return "Encoding(" + strconv.Itoa(int(e)) + ")"
+}
+
+// Segmentation describes how a text message is sent.
+type Segmentation struct {
+	// Encoding is the encoding the message is sent with.
+	Encoding Encoding
+	// Units is the length of the message in septets for GSM-7, or UTF-16 code units for UCS-2.
+	Units int
+	// Parts is the number of short messages sent.
+	Parts int
+	// Remaining is the number of units still free in the last part.
+	Remaining int
+	// NonGSM lists the characters that are not in the GSM 7-bit alphabet
+	// and forced UCS-2, in the order they first appear.
+	NonGSM []rune
+	// LockingShift and SingleShift are the national language tables of a GSM-7 message,
+	// zero for the default alphabet.
+	LockingShift Language
+	SingleShift  Language
+}
+
+// Analyze returns how Send splits and encodes a message, with 8-bit concatenation references
+// and no national languages.
+func Analyze(message string) Segmentation {
+	return analyze(message, false, concatRef8, nil)
+}
+
+// Analyze returns how Send splits and encodes a message with the options of the client.
+func (c *Client) Analyze(message string) Segmentation {
+	return c.AnalyzeWithOptions(message, nil)
+}
+
+// AnalyzeWithOptions returns how SendWithOptions splits and encodes a message,
+// counting the transliterated message if opt.Transliterate is set.
+func (c *Client) AnalyzeWithOptions(message string, opt *SendOptions) Segmentation {
+	if opt == nil {
+		opt = &SendOptions{}
+	}
+	return analyze(message, opt.Transliterate, c.concatRefBits, c.languages)
+}
+
+// analyze splits the message like Send does.
+func analyze(message string, transliterate bool, refBits int, languages []Language) Segmentation {
+	message, msgType, sh, msgParts, _ := chooseText(message, transliterate, refBits, languages)
+	s := Segmentation{Parts: len(msgParts), LockingShift: sh.locking, SingleShift: sh.single}
+	count := func(message string) int {
+		septets, _ := sh.encode(message)
+		return len(septets)
+	}
+	if sh == (shift{}) {
+		count = gsmSeptetCount
+	}
+	singleMax, multiMax := sh.capacity(refBits)
+	if msgType == transparentData {
+		s.Encoding = EncodingUCS2
+		count, singleMax, multiMax = ucs2Units, ucs2MaxSinglePart, ucs2MaxMultiPart
+		if refBits == concatRef16 {
+			multiMax = ucs2MaxMultiPart16
+		}
+		s.NonGSM = nonGsmRunes(message)
+	}
+	s.Units = count(message)
+	last := count(msgParts[len(msgParts)-1])
+	if len(msgParts) == 1 {
+		s.Remaining = singleMax - last
+	} else {
+		s.Remaining = multiMax - last
+	}
+	return s
+}
+
+// gsmSeptetCount returns the number of septets of a message of the GSM 7-bit alphabet.
+func gsmSeptetCount(message string) int {
+	return len(charset.Encode7Bit(message))
+}
+
+// nonGsmRunes returns the distinct characters of the message that are not in the GSM 7-bit alphabet.
+func nonGsmRunes(message string) []rune {
+	runes := make([]rune, 0)
+	seen := make(map[rune]bool)
+	for _, r := range message {
+		if !seen[r] && !charset.IsGsmAlpha(string(r)) {
+			runes = append(runes, r)
+		}
+		seen[r] = true
+	}
+	return runes
+}
@@ -0,0 +1,59 @@
+package ucp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	analyzeTestCases := []struct {
+		name     string
+		input    string
+		expected Segmentation
+	}{
+		{"empty", "", Segmentation{EncodingGSM7, 0, 1, 160, nil, 0, 0}},
+		{"hello world", "hello world", Segmentation{EncodingGSM7, 11, 1, 149, nil, 0, 0}},
+		{"extension characters take two septets", "{€}", Segmentation{EncodingGSM7, 6, 1, 154, nil, 0, 0}},
+		{"161 septets", strings.Repeat("a", 161), Segmentation{EncodingGSM7, 161, 2, 145, nil, 0, 0}},
+		{"extension character near the single part limit", strings.Repeat("a", 152) + "€", Segmentation{EncodingGSM7, 154, 1, 6, nil, 0, 0}},
+		{"escape sequence moved to the next part of a multipart message", strings.Repeat("a", 160) + "€", Segmentation{EncodingGSM7, 162, 2, 144, nil, 0, 0}},
+		{"cyrillic", "Привет", Segmentation{EncodingUCS2, 6, 1, 64, []rune("Привет"), 0, 0}},
+		{"emoji", "ok 😃😃", Segmentation{EncodingUCS2, 7, 1, 63, []rune{'😃'}, 0, 0}},
+		{"71 code units", strings.Repeat("ж", 71), Segmentation{EncodingUCS2, 71, 2, 63, []rune{'ж'}, 0, 0}},
+	}
+
+	for _, testCase := range analyzeTestCases {
+		actual := Analyze(testCase.input)
+		if !reflect.DeepEqual(testCase.expected, actual) {
+			t.Errorf("testcase %s: Expected %+v, got %+v\n", testCase.name, testCase.expected, actual)
+		}
+	}
+}
+
+func TestClientAnalyze(t *testing.T) {
+	client := &Client{concatRefBits: concatRef16}
+	expected := Segmentation{EncodingGSM7, 161, 2, 143, nil, 0, 0}
+	if actual := client.Analyze(strings.Repeat("a", 161)); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %+v, got %+v\n", expected, actual)
+	}
+}
+
+func TestClientAnalyzeWithOptions(t *testing.T) {
+	client := &Client{concatRefBits: concatRef8}
+	testCases := []struct {
+		name     string
+		input    string
+		opt      *SendOptions
+		expected Segmentation
+	}{
+		{"no options", "“hello” world", nil, Segmentation{EncodingUCS2, 13, 1, 57, []rune{'“', '”'}, 0, 0}},
+		{"transliterated", "“hello” world", &SendOptions{Transliterate: true}, Segmentation{EncodingGSM7, 13, 1, 147, nil, 0, 0}},
+		{"UCS-2 anyway", "“hello” 😃", &SendOptions{Transliterate: true}, Segmentation{EncodingUCS2, 10, 1, 60, []rune{'“', '”', '😃'}, 0, 0}},
+	}
+	for _, testCase := range testCases {
+		if actual := client.AnalyzeWithOptions(testCase.input, testCase.opt); !reflect.DeepEqual(testCase.expected, actual) {
+			t.Errorf("%s: Expected %+v, got %+v\n", testCase.name, testCase.expected, actual)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"container/ring"
 	"context"
 	"fmt"
-	"math/rand"
 	"net"
 	"sync"
 	"time"
@@ -31,8 +30,26 @@ type Client struct {
 	deliveryHandler Handler
 	// shortMessageHandler is called whenever a deliver short message packet is received from the SMSC.
 	shortMessageHandler Handler
+	// incompleteHandler is called with the multipart mobile-originating messages missing parts.
+	incompleteHandler IncompleteHandler
+	// reassemblyTimeout is how long multipart mobile-originating messages wait for missing parts
+	reassemblyTimeout time.Duration
+	// maxPending is the maximum number of multipart mobile-originating messages waiting for parts
+	maxPending int
+	// reassemblyStore keeps the parts of multipart mobile-originating messages
+	reassemblyStore ReassemblyStore
+	// reportHandler is called with the parsed delivery notifications received from the SMSC.
+	reportHandler ReportHandler
 	// tps mobile-terminating transactions per second.
 	tps int
+	// concatRefBits is the width of the concatenation reference of multipart messages, 8 or 16
+	concatRefBits int
+	// refAllocator hands out the concatenation references of multipart messages
+	refAllocator *RefAllocator
+	// location is the timezone of the SMSC
+	location *time.Location
+	// languages are the national languages whose shift tables may encode text messages
+	languages []Language
 	// muconn  guards concurrent access to net.Conn
 	muconn *sync.Mutex
 	// conn is the underlying network connection
@@ -49,6 +66,8 @@ type Client struct {
 	deliverNotifCh chan []string
 	// deliverMsgCh is a channel of deliver short messages (mobile-originating messages)
 	deliverMsgCh chan []string
+	// alertRespCh is a channel of alert responses
+	alertRespCh chan []string
 	// deliverMsgPartCh is a channel of incomplete mobile-originating multi-part messages
 	deliverMsgPartCh chan deliverMsgPart
 	// deliverMsgCompleteCh is a channel of completed mobile-originating multi-part messages
@@ -67,6 +86,20 @@ type Client struct {
 	timeout time.Duration
 	// logger logs the debug messages
 	logger Logger
+	// onFrame is called for every frame exchanged with the SMSC
+	onFrame FrameHook
+	// correlationStore maps message IDs to the correlation IDs given to SendWithOptions
+	correlationStore CorrelationStore
+}
+
+// SendResult is the result of SendWithOptions.
+type SendResult struct {
+	// CorrelationID is the correlation ID given in the SendOptions.
+	CorrelationID string
+	// IDs is the list of message IDs from the SMSC, one per message part.
+	IDs []string
+	// Substitutions are the characters replaced by SendOptions.Transliterate.
+	Substitutions []Substitution
 }
 
 // New returns a UCP client based on the given options.
@@ -78,9 +111,14 @@ func New(opt *Options) *Client {
 		password:             opt.Password,
 		accessCode:           opt.AccessCode,
 		tps:                  opt.Tps,
+		concatRefBits:        opt.ConcatRefBits,
+		refAllocator:         opt.RefAllocator,
+		languages:            opt.Languages,
+		location:             opt.Location,
 		submitSmRespCh:       make(chan []string, 1),
 		deliverNotifCh:       make(chan []string, 1),
 		deliverMsgCh:         make(chan []string, 1),
+		alertRespCh:          make(chan []string, 1),
 		deliverMsgPartCh:     make(chan deliverMsgPart, 1),
 		deliverMsgCompleteCh: make(chan deliverMsgPart, 1),
 		closeChan:            make(chan struct{}),
@@ -88,8 +126,15 @@ func New(opt *Options) *Client {
 		timeout:              opt.Timeout,
 		deliveryHandler:      DefaultHandler,
 		shortMessageHandler:  DefaultHandler,
+		incompleteHandler:    opt.IncompleteMessageHandler,
+		reassemblyTimeout:    opt.ReassemblyTimeout,
+		maxPending:           opt.MaxPendingMessages,
+		reassemblyStore:      opt.ReassemblyStore,
+		reportHandler:        opt.DeliveryReportHandler,
 		wg:                   new(sync.WaitGroup),
 		logger:               opt.Logger,
+		onFrame:              opt.OnFrame,
+		correlationStore:     opt.CorrelationStore,
 		muconn:               new(sync.Mutex),
 		mu:                   new(sync.Mutex),
 	}
@@ -107,7 +152,8 @@ func (c *Client) Connect() error {
 	c.conn = conn
 	c.reader = bufio.NewReader(conn)
 	c.writer = bufio.NewWriter(conn)
-	_, err = c.writer.Write(login(c.nextRefNum(), c.user, c.password))
+	loginPacket := login(c.nextRefNum(), c.user, c.password)
+	_, err = c.writer.Write(loginPacket)
 	if err != nil {
 		return err
 	}
@@ -115,22 +161,28 @@ func (c *Client) Connect() error {
 	if err != nil {
 		return err
 	}
+	c.onFrame.trace(Outbound, loginPacket)
 	resp, err := c.reader.ReadString(etx)
 	if err != nil {
 		return err
 	}
+	c.onFrame.trace(Inbound, []byte(resp))
 	err = parseSessionResp(resp)
 	if err != nil {
 		return err
 	}
 
 	c.rateLimiter = rate.NewLimiter(rate.Limit(c.GetTps()), 1)
-	sendAlert(c.nextRefNum(), c.user, c.writer, c.wg, c.closeChan, c.alertInterval, c.muconn, c)
-	readLoop(c.reader, c.wg, c.closeChan, c.submitSmRespCh, c.deliverNotifCh, c.deliverMsgCh, c)
-	readDeliveryNotif(c.writer, c.wg, c.closeChan, c.deliverNotifCh, c.deliveryHandler, c.accessCode, c.muconn, c)
-	readDeliveryMsg(c.writer, c.wg, c.closeChan, c.deliverMsgCh, c.deliverMsgPartCh, c.deliverMsgCompleteCh, c.muconn, c)
-	readPartialDeliveryMsg(c.wg, c.closeChan, c.deliverMsgPartCh, c.deliverMsgCompleteCh, c)
-	readCompleteDeliveryMsg(c.wg, c.closeChan, c.deliverMsgCompleteCh, c.shortMessageHandler, c.accessCode, c)
+	sendAlert(c.nextRefNum(), c.user, c.writer, c.wg, c.closeChan, c.alertInterval, c.muconn, c.onFrame, c)
+	readLoop(c.reader, c.wg, c.closeChan, c.submitSmRespCh, c.deliverNotifCh, c.deliverMsgCh, c.alertRespCh, c.onFrame, c)
+	readDeliveryNotif(c.writer, c.wg, c.closeChan, c.deliverNotifCh, c.deliveryHandler, c.reportHandler, c.accessCode,
+		c.correlationStore, c.muconn, c.onFrame, c)
+	readDeliveryMsg(c.writer, c.wg, c.closeChan, c.deliverMsgCh, c.deliverMsgPartCh, c.deliverMsgCompleteCh,
+		c.reassemblyStore, c.muconn, c.onFrame, c)
+	readPartialDeliveryMsg(c.wg, c.closeChan, c.deliverMsgPartCh, c.deliverMsgCompleteCh, c.reassemblyStore,
+		c.reassemblyTimeout, c.maxPending, c)
+	readCompleteDeliveryMsg(c.wg, c.closeChan, c.deliverMsgCompleteCh, c.shortMessageHandler, c.incompleteHandler,
+		c.accessCode, c)
 	return err
 }
 
@@ -189,6 +241,21 @@ func (c *Client) DeliveryHandler(handler Handler) {
 	c.deliveryHandler = handler
 }
 
+// DeliveryReportHandler sets the handler of parsed delivery notifications.
+func (c *Client) DeliveryReportHandler(handler ReportHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reportHandler = handler
+}
+
+// IncompleteMessageHandler sets the handler of multipart mobile-originating messages missing parts.
+// Without it, such messages are logged and dropped.
+func (c *Client) IncompleteMessageHandler(handler IncompleteHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.incompleteHandler = handler
+}
+
 // ShortMessageHandler sets the delivery short message handler.
 func (c *Client) ShortMessageHandler(handler Handler) {
 	c.mu.Lock()
@@ -197,21 +264,93 @@ func (c *Client) ShortMessageHandler(handler Handler) {
 }
 
 // Send will send the message to the receiver with a sender mask.
+// The type of the sender, alphanumeric or a number, is detected as for OriginatorAuto.
 // It returns a list of message IDs from the SMSC.
 func (c *Client) Send(sender, receiver, message string) ([]string, error) {
+	result, err := c.SendWithOptions(sender, receiver, message, nil)
+	return result.IDs, err
+}
+
+// SendWithOptions is like Send, with per-message options.
+// The result holds the message IDs of the parts sent before an error occurred.
+// The correlation ID, if any, is saved for every acknowledged part and
+// reported with the delivery reports of the message, unless notifications are turned off.
+func (c *Client) SendWithOptions(sender, receiver, message string, opt *SendOptions) (*SendResult, error) {
+	if opt == nil {
+		opt = &SendOptions{}
+	}
+	oadc, err := newOriginator(sender, opt.Originator)
+	if err != nil {
+		return &SendResult{CorrelationID: opt.CorrelationID}, err
+	}
+	params, err := newSubmitParams(opt, time.Now(), c.location)
+	if err != nil {
+		return &SendResult{CorrelationID: opt.CorrelationID}, err
+	}
 	c.muconn.Lock()
 	defer c.muconn.Unlock()
 
-	msgType := getMessageType(message)
-	msgParts := getMessageParts(message)
-	refNum := rand.Intn(maxRefNum)
-	ids := make([]string, len(msgParts))
+	_, msgType, sh, msgParts, substitutions := chooseText(message, opt.Transliterate, c.concatRefBits, c.languages)
+	var refNum int
+	if len(msgParts) > 1 {
+		refNum = c.refAllocator.Next(receiver)
+	}
+	billingID := c.GetBillingID()
+	correlationID := opt.CorrelationID
+	if opt.Notifications == NotifyNone {
+		// no delivery report will come to look the IDs up and delete them
+		correlationID = ""
+	}
+	ids, err := c.submitParts(len(msgParts), func(trn []byte, i int) []byte {
+		return encodeMessage(trn, oadc, receiver, msgParts[i], msgType,
+			billingID, refNum, i+1, len(msgParts), c.concatRefBits, sh, params)
+	}, correlationID)
+	return &SendResult{CorrelationID: opt.CorrelationID, IDs: ids, Substitutions: substitutions}, err
+}
+
+// SendBinary sends an 8-bit payload to the receiver with a sender mask, e.g. a message
+// for an application port of the handset or a SIM toolkit push.
+// udh is the user data header of every part, the concatenation element is added
+// if the payload does not fit in one part. dcs is the data coding scheme, e.g. 0x04
+// for 8-bit data, or 0xF6 for SIM specific class 2 messages.
+// It returns a list of message IDs from the SMSC, or ErrUDHTooLong if udh leaves no room for the payload.
+func (c *Client) SendBinary(sender, receiver string, payload []byte, udh UDH, dcs byte) ([]string, error) {
+	oadc, err := newOriginator(sender, OriginatorAuto)
+	if err != nil {
+		return nil, err
+	}
+	msgParts, err := getBinaryParts(payload, udh, c.concatRefBits)
+	if err != nil {
+		return nil, err
+	}
+	c.muconn.Lock()
+	defer c.muconn.Unlock()
+
+	var refNum int
+	if len(msgParts) > 1 {
+		refNum = c.refAllocator.Next(receiver)
+	}
+	billingID := c.GetBillingID()
+	return c.submitParts(len(msgParts), func(trn []byte, i int) []byte {
+		partUDH := udh
+		if len(msgParts) > 1 {
+			partUDH = append(append(UDH(nil), udh...), Concatenation(refNum, len(msgParts), i+1, c.concatRefBits))
+		}
+		return encodeBinary(trn, oadc, receiver, msgParts[i], partUDH, dcs, billingID)
+	}, "")
+}
+
+// submitParts writes the n submit packets built by build and waits for their responses.
+// It returns the message IDs of the parts acknowledged before an error occurred.
+// The correlation ID, if any, is saved for every acknowledged part.
+// c.muconn must be held.
+func (c *Client) submitParts(n int, build func(trn []byte, i int) []byte, correlationID string) ([]string, error) {
+	ids := make([]string, n)
 	c.rateLimiter.SetLimit(rate.Limit(c.GetTps()))
-	for i := 0; i < len(msgParts); i++ {
-		sendPacket := encodeMessage(c.nextRefNum(), sender, receiver, msgParts[i], msgType,
-			c.GetBillingID(), refNum, i+1, len(msgParts))
+	for i := 0; i < n; i++ {
+		sendPacket := build(c.nextRefNum(), i)
 		c.rateLimiter.Wait(context.Background())
-		c.Printf("sendPacket: %q\n", sendPacket)
+		c.Printf("sendPacket: %q correlationID: %q\n", sendPacket, correlationID)
 		if _, err := c.writer.Write(sendPacket); err != nil {
 			c.Printf("error writing sendPacket: %v\n", err)
 			return ids, err
@@ -220,25 +359,68 @@ func (c *Client) Send(sender, receiver, message string) ([]string, error) {
 			c.Printf("error flushing sendPacket: %v\n", err)
 			return ids, err
 		}
+		c.onFrame.trace(Outbound, sendPacket)
 		select {
 		case fields := <-c.submitSmRespCh:
 			ack := fields[ackIndex]
 			if ack == negativeAck {
 				errMsg := fields[len(fields)-errMsgOffset]
 				errCode := fields[len(fields)-errCodeOffset]
-				c.Printf("negative ack, errMsg: %v errCode: %v\n", errMsg, errCode)
+				c.Printf("negative ack, errMsg: %v errCode: %v correlationID: %q\n", errMsg, errCode, correlationID)
 				return ids, &UcpError{errCode, errMsg}
 			}
 			id := fields[submitSmIdIndex]
 			ids[i] = id
+			if correlationID != "" {
+				// the part is sent, a failing store must not fail the send
+				if err := c.correlationStore.Save(id, correlationID); err != nil {
+					c.Printf("error saving correlationID %q of %v: %v\n", correlationID, id, err)
+				}
+			}
 		case <-time.After(c.timeout):
-			c.Printf("send timeout\n")
+			c.Printf("send timeout, correlationID: %q\n", correlationID)
 			return ids, &UcpError{errCodeTimeout, "Network time-out"}
 		}
 	}
 	return ids, nil
 }
 
+// Ping sends an alert operation to the SMSC and waits for its response.
+func (c *Client) Ping() error {
+	c.muconn.Lock()
+	defer c.muconn.Unlock()
+
+	// discard a pending response to an earlier keep-alive
+	select {
+	case <-c.alertRespCh:
+	default:
+	}
+	pingPacket := ping(c.nextRefNum(), c.user)
+	c.Printf("pingPacket: %q\n", pingPacket)
+	if _, err := c.writer.Write(pingPacket); err != nil {
+		c.Printf("error writing pingPacket: %v\n", err)
+		return err
+	}
+	if err := c.writer.Flush(); err != nil {
+		c.Printf("error flushing pingPacket: %v\n", err)
+		return err
+	}
+	c.onFrame.trace(Outbound, pingPacket)
+	select {
+	case fields := <-c.alertRespCh:
+		if fields[ackIndex] == negativeAck {
+			errMsg := fields[len(fields)-errMsgOffset]
+			errCode := fields[len(fields)-errCodeOffset]
+			c.Printf("negative ack, errMsg: %v errCode: %v\n", errMsg, errCode)
+			return &UcpError{errCode, errMsg}
+		}
+		return nil
+	case <-time.After(c.timeout):
+		c.Printf("ping timeout\n")
+		return &UcpError{errCodeTimeout, "Network time-out"}
+	}
+}
+
 // Close will close the UCP connection
 func (c *Client) Close() {
 	c.Printf("closing client\n")
@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"golang.org/x/time/rate"
 	"log"
+	"net"
 	"os"
 	"reflect"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestInitRefNum(t *testing.T) {
@@ -44,6 +46,7 @@ func TestSend(t *testing.T) {
 		rateLimiter:    rate.NewLimiter(rate.Limit(1), 1),
 		writer:         bufio.NewWriter(buf),
 		submitSmRespCh: submitSmRespCh,
+		timeout:        time.Second,
 	}
 	ack := []string{"01", "00044", "R", "51", "A", "", "09191234567:110917173639", "95"}
 	client.initRefNum()
@@ -73,3 +76,208 @@ func TestSend(t *testing.T) {
 	}
 
 }
+
+func TestSendWithOptions(t *testing.T) {
+	submitSmRespCh := make(chan []string, 1)
+	store := NewMemoryCorrelationStore()
+	client := &Client{
+		mu:               &sync.Mutex{},
+		muconn:           &sync.Mutex{},
+		rateLimiter:      rate.NewLimiter(rate.Limit(1), 1),
+		writer:           bufio.NewWriter(new(bytes.Buffer)),
+		submitSmRespCh:   submitSmRespCh,
+		timeout:          time.Second,
+		correlationStore: store,
+	}
+	client.initRefNum()
+	submitSmRespCh <- []string{"01", "00044", "R", "51", "A", "", "09191234567:110917173639", "95"}
+
+	result, err := client.SendWithOptions("test", "09191234567", "hello world", &SendOptions{CorrelationID: "order-42"})
+	if err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	expected := &SendResult{CorrelationID: "order-42", IDs: []string{"09191234567:110917173639"}}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("Expected %+v got %+v\n", expected, result)
+	}
+	if correlationID, ok, _ := store.Load("09191234567:110917173639"); !ok || correlationID != "order-42" {
+		t.Errorf("Expected the correlation ID to be saved, got %q %v\n", correlationID, ok)
+	}
+}
+
+func TestSendWithOptionsNotifyNone(t *testing.T) {
+	submitSmRespCh := make(chan []string, 1)
+	store := NewMemoryCorrelationStore()
+	client := &Client{
+		mu:               &sync.Mutex{},
+		muconn:           &sync.Mutex{},
+		rateLimiter:      rate.NewLimiter(rate.Limit(1), 1),
+		writer:           bufio.NewWriter(new(bytes.Buffer)),
+		submitSmRespCh:   submitSmRespCh,
+		timeout:          time.Second,
+		correlationStore: store,
+	}
+	client.initRefNum()
+	submitSmRespCh <- []string{"01", "00044", "R", "51", "A", "", "09191234567:110917173639", "95"}
+
+	result, err := client.SendWithOptions("test", "09191234567", "hello world", &SendOptions{CorrelationID: "order-42", Notifications: NotifyNone})
+	if err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	if result.CorrelationID != "order-42" {
+		t.Errorf("Expected correlation ID order-42, got %q\n", result.CorrelationID)
+	}
+	if _, ok, _ := store.Load("09191234567:110917173639"); ok {
+		t.Errorf("Expected the correlation ID not to be saved\n")
+	}
+}
+
+func TestSendInvalidOriginator(t *testing.T) {
+	client := &Client{mu: &sync.Mutex{}, muconn: &sync.Mutex{}}
+	result, err := client.SendWithOptions("0917ABC", "09191234567", "hello", &SendOptions{Originator: OriginatorNational})
+	if err != ErrInvalidOriginator || len(result.IDs) != 0 {
+		t.Errorf("Expected %v and no IDs, got %v %v\n", ErrInvalidOriginator, err, result.IDs)
+	}
+}
+
+func TestSendWithOptionsTransliterate(t *testing.T) {
+	sendTransliterateTestCases := []struct {
+		name          string
+		input         string
+		msgType       string
+		substitutions []Substitution
+	}{
+		{"smart quote", "It’s on", alphaNumericMessage, []Substitution{{2, '’', "'"}}},
+		{"still ucs2", "Привет’", transparentData, nil},
+	}
+
+	for _, testCase := range sendTransliterateTestCases {
+		buf := new(bytes.Buffer)
+		submitSmRespCh := make(chan []string, 1)
+		client := &Client{
+			mu:             &sync.Mutex{},
+			muconn:         &sync.Mutex{},
+			rateLimiter:    rate.NewLimiter(rate.Inf, 1),
+			writer:         bufio.NewWriter(buf),
+			submitSmRespCh: submitSmRespCh,
+			timeout:        time.Second,
+		}
+		client.initRefNum()
+		submitSmRespCh <- []string{"00", "00044", "R", "51", "A", "", "09191234567:110917173639", "95"}
+
+		result, err := client.SendWithOptions("test", "09191234567", testCase.input, &SendOptions{Transliterate: true})
+		if err != nil {
+			t.Fatalf("testcase %s: Expected nil error, got %v\n", testCase.name, err)
+		}
+		if !reflect.DeepEqual(testCase.substitutions, result.Substitutions) {
+			t.Errorf("testcase %s: Expected %v got %v\n", testCase.name, testCase.substitutions, result.Substitutions)
+		}
+		pdu, err := Describe(buf.Bytes())
+		if err != nil {
+			t.Fatalf("testcase %s: invalid frame %q: %v\n", testCase.name, buf.Bytes(), err)
+		}
+		if mt, _ := pdu.Field("MT"); mt != testCase.msgType {
+			t.Errorf("testcase %s: Expected MT %s got %s\n", testCase.name, testCase.msgType, mt)
+		}
+	}
+}
+
+func TestSendBinary(t *testing.T) {
+	buf := new(bytes.Buffer)
+	submitSmRespCh := make(chan []string)
+	client := &Client{
+		mu:             &sync.Mutex{},
+		muconn:         &sync.Mutex{},
+		rateLimiter:    rate.NewLimiter(rate.Inf, 1),
+		writer:         bufio.NewWriter(buf),
+		submitSmRespCh: submitSmRespCh,
+		timeout:        time.Second,
+		refAllocator:   NewRefAllocator(concatRef8),
+	}
+	client.initRefNum()
+	go func() {
+		submitSmRespCh <- []string{"00", "00044", "R", "51", "A", "", "09191234567:110917173639", "95"}
+		submitSmRespCh <- []string{"01", "00044", "R", "51", "A", "", "09191234567:110917173640", "96"}
+	}()
+
+	udh := UDH{PortAddressing16(2948, 9200)}
+	ids, err := client.SendBinary("test", "09191234567", make([]byte, 200), udh, 0x04)
+	if err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	if expected := []string{"09191234567:110917173639", "09191234567:110917173640"}; !reflect.DeepEqual(expected, ids) {
+		t.Errorf("Expected %v got %v\n", expected, ids)
+	}
+	for i, frame := range bytes.SplitAfter(buf.Bytes(), []byte{etx})[:2] {
+		pdu, err := Describe(frame)
+		if err != nil || len(pdu.Problems) > 0 {
+			t.Fatalf("part %d: invalid frame %q: %v %v\n", i+1, frame, err, pdu.Problems)
+		}
+		xser, _ := pdu.Field("Xser")
+		x, _ := ParseXSer(xser)
+		partUDH, _, _ := x.UDH()
+		_, parts, part, _ := partUDH.Concat()
+		destination, _, _ := partUDH.Ports()
+		if parts != 2 || part != i+1 || destination != 2948 {
+			t.Errorf("part %d: expected the port and concatenation header, got %v\n", i+1, partUDH)
+		}
+		if mt, _ := pdu.Field("MT"); mt != transparentData {
+			t.Errorf("part %d: expected MT %v, got %v\n", i+1, transparentData, mt)
+		}
+	}
+}
+
+func TestSendBinaryUDHTooLong(t *testing.T) {
+	buf := new(bytes.Buffer)
+	client := &Client{
+		mu:           &sync.Mutex{},
+		muconn:       &sync.Mutex{},
+		rateLimiter:  rate.NewLimiter(rate.Inf, 1),
+		writer:       bufio.NewWriter(buf),
+		timeout:      time.Second,
+		refAllocator: NewRefAllocator(concatRef8),
+	}
+	client.initRefNum()
+	udh := UDH{{ID: 0x70, Data: make([]byte, 132)}}
+	ids, err := client.SendBinary("test", "09191234567", make([]byte, 10), udh, 0x04)
+	if err != ErrUDHTooLong || len(ids) != 0 {
+		t.Errorf("Expected %v, got %v %v\n", ErrUDHTooLong, ids, err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected nothing to be written, got %q\n", buf.Bytes())
+	}
+}
+
+func TestPing(t *testing.T) {
+	conn, smsc := net.Pipe()
+	defer conn.Close()
+	alertRespCh := make(chan []string, 1)
+	client := &Client{
+		mu:          &sync.Mutex{},
+		muconn:      &sync.Mutex{},
+		logger:      log.New(os.Stdout, "debug ", 0),
+		writer:      bufio.NewWriter(conn),
+		alertRespCh: alertRespCh,
+		user:        "emi_client",
+		timeout:     time.Second,
+	}
+	client.initRefNum()
+
+	pingCh := make(chan []byte, 1)
+	go func() {
+		defer smsc.Close()
+		// answer the alert only once it has been written
+		packet, _ := bufio.NewReader(smsc).ReadBytes(etx)
+		pingCh <- packet
+		alertRespCh <- []string{"00", "00020", "R", "31", "A", "", "5F"}
+	}()
+
+	if err := client.Ping(); err != nil {
+		t.Errorf("Expected nil error, got %v\n", err)
+	}
+	expectedBytesWritten := []byte("\x0200/00032/O/31/emi_client/0539/0C\x03")
+	actualBytesWritten := <-pingCh
+	if !bytes.Equal(expectedBytesWritten, actualBytesWritten) {
+		t.Errorf("Expected %q got %q\n", expectedBytesWritten, actualBytesWritten)
+	}
+}
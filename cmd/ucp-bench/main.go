@@ -0,0 +1,228 @@
+// Command ucp-bench measures the submit throughput and latency of ucp.Client.
+//
+// By default it starts an in-process SMSC simulator (package ucptest) and
+// drives it with concurrent Send calls over one or more sessions.
+// Send is serialized per session, so the senders of a session take turns:
+// the latency is timed from the turn of a sender and the time spent
+// waiting for it is reported as queue time.
+//
+//	ucp-bench -sessions 4 -window 8 -n 20000 -mix gsm7=70,ucs2=20,multipart=10
+//
+// Use -addr to benchmark a real SMSC or an external simulator instead.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gsm/ucp"
+	"github.com/go-gsm/ucp/ucptest"
+)
+
+// messages are the sample messages of every kind of the mix.
+var messages = map[string]string{
+	"gsm7":      "Your verification code is 482913. It expires in 5 minutes, do not share it with anyone.",
+	"ucs2":      "Ваш код подтверждения 482913. Никому его не сообщайте 👍",
+	"multipart": strings.Repeat("This is a long marketing text that needs several parts. ", 6),
+}
+
+// stats collects the outcome of every Send call.
+type stats struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	waits     []time.Duration
+	parts     int64
+	nacks     int64
+	timeouts  int64
+	errors    int64
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("ucp-bench: ")
+	addr := flag.String("addr", "", "SMSC `host:port`, an in-process simulator is started if empty")
+	user := flag.String("user", "emi_client", "SMSC user")
+	password := flag.String("password", "password", "SMSC password")
+	sessions := flag.Int("sessions", 1, "number of client sessions")
+	window := flag.Int("window", 1, "concurrent senders per session, taking turns to Send")
+	total := flag.Int("n", 10000, "number of messages to send")
+	tps := flag.Int("tps", 1000000, "mobile-terminating transactions per second per session")
+	timeout := flag.Duration("timeout", 5*time.Second, "submit response timeout")
+	mix := flag.String("mix", "gsm7=70,ucs2=20,multipart=10", "message mix as kind=weight pairs, kinds: gsm7, ucs2, multipart")
+	nackRate := flag.Float64("nack-rate", 0, "fraction of submits the in-process simulator rejects")
+	serverDelay := flag.Duration("server-delay", 0, "response delay of the in-process simulator")
+	flag.Parse()
+
+	kinds, err := parseMix(*mix)
+	if err != nil {
+		log.Fatal(err)
+	}
+	simulated := *addr == ""
+	if simulated {
+		server := startSimulator(*user, *password, *nackRate, *serverDelay)
+		defer server.Close()
+		*addr = server.Addr
+	}
+
+	clients := make([]*ucp.Client, *sessions)
+	for i := range clients {
+		clients[i] = ucp.New(&ucp.Options{
+			Addr:     *addr,
+			User:     *user,
+			Password: *password,
+			Tps:      *tps,
+			Timeout:  *timeout,
+		})
+		// discard delivery notifications and mobile-originating messages
+		clients[i].DeliveryHandler(func(sender, receiver, messageID, message, accessCode string) {})
+		clients[i].ShortMessageHandler(func(sender, receiver, messageID, message, accessCode string) {})
+		if err := clients[i].Connect(); err != nil {
+			log.Fatalf("session %d: %v", i, err)
+		}
+		defer clients[i].Close()
+	}
+
+	st := &stats{latencies: make([]time.Duration, 0, *total), waits: make([]time.Duration, 0, *total)}
+	var next int64 = -1
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		// turn serializes the senders of the session ahead of Send, to time them apart from the queue
+		turn := new(sync.Mutex)
+		for w := 0; w < *window; w++ {
+			wg.Add(1)
+			go func(client *ucp.Client, turn *sync.Mutex) {
+				defer wg.Done()
+				for {
+					i := atomic.AddInt64(&next, 1)
+					if i >= int64(*total) {
+						return
+					}
+					receiver := fmt.Sprintf("0919%07d", i)
+					queued := time.Now()
+					turn.Lock()
+					sendStart := time.Now()
+					ids, err := client.Send("Bench", receiver, messages[kinds[int(i)%len(kinds)]])
+					latency := time.Since(sendStart)
+					turn.Unlock()
+					st.record(sendStart.Sub(queued), latency, ids, err)
+				}
+			}(client, turn)
+		}
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	st.report(os.Stdout, elapsed, *total, simulated, after.Mallocs-before.Mallocs, after.TotalAlloc-before.TotalAlloc)
+}
+
+// parseMix expands the weighted message mix into a list of kinds to cycle through.
+func parseMix(mix string) ([]string, error) {
+	kinds := make([]string, 0)
+	for _, pair := range strings.Split(mix, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid mix entry %q", pair)
+		}
+		if _, ok := messages[kv[0]]; !ok {
+			return nil, fmt.Errorf("unknown message kind %q", kv[0])
+		}
+		weight, err := strconv.Atoi(kv[1])
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight in mix entry %q", pair)
+		}
+		for i := 0; i < weight; i++ {
+			kinds = append(kinds, kv[0])
+		}
+	}
+	if len(kinds) == 0 {
+		return nil, fmt.Errorf("empty message mix")
+	}
+	rand.Shuffle(len(kinds), func(i, j int) { kinds[i], kinds[j] = kinds[j], kinds[i] })
+	return kinds, nil
+}
+
+// startSimulator starts an in-process SMSC that rejects nackRate of the submits.
+func startSimulator(user, password string, nackRate float64, delay time.Duration) *ucptest.Server {
+	server := ucptest.NewServer(user, password)
+	if nackRate > 0 {
+		r := rand.New(rand.NewSource(1))
+		server.InjectSubmitFault(ucptest.SubmitFault{
+			// Match is called with the server locked, r needs no extra locking
+			Match:        func(ucptest.Submission) bool { return r.Float64() < nackRate },
+			ErrorCode:    "05",
+			ErrorMessage: "Call barring active",
+			Delay:        delay,
+		})
+	}
+	if delay > 0 {
+		server.InjectSubmitFault(ucptest.SubmitFault{Delay: delay})
+	}
+	return server
+}
+
+func (st *stats) record(wait, latency time.Duration, ids []string, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.waits = append(st.waits, wait)
+	st.latencies = append(st.latencies, latency)
+	// parts that were not acknowledged have an empty ID
+	for _, id := range ids {
+		if id != "" {
+			st.parts++
+		}
+	}
+	if err == nil {
+		return
+	}
+	switch ucpErr, ok := err.(*ucp.UcpError); {
+	case ok && ucpErr.Code == "010":
+		st.timeouts++
+	case ok:
+		st.nacks++
+	default:
+		st.errors++
+	}
+}
+
+func (st *stats) report(w io.Writer, elapsed time.Duration, total int, simulated bool, mallocs, bytes uint64) {
+	percentiles := func(durations []time.Duration) string {
+		if len(durations) == 0 {
+			durations = []time.Duration{0}
+		}
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		percentile := func(p float64) time.Duration {
+			return durations[int(p*float64(len(durations)-1))]
+		}
+		return fmt.Sprintf("p50 %v  p90 %v  p99 %v  max %v", percentile(0.50), percentile(0.90), percentile(0.99), percentile(1))
+	}
+	seconds := elapsed.Seconds()
+	fmt.Fprintf(w, "messages    %d in %v\n", total, elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "throughput  %.1f msg/s, %.1f submits/s\n", float64(total)/seconds, float64(st.parts)/seconds)
+	fmt.Fprintf(w, "latency     %s\n", percentiles(st.latencies))
+	fmt.Fprintf(w, "queue       %s\n", percentiles(st.waits))
+	fmt.Fprintf(w, "nacks       %d (%.2f%%)\n", st.nacks, 100*float64(st.nacks)/float64(total))
+	fmt.Fprintf(w, "timeouts    %d\n", st.timeouts)
+	fmt.Fprintf(w, "errors      %d\n", st.errors)
+	note := ""
+	if simulated {
+		note = " (includes the in-process simulator)"
+	}
+	fmt.Fprintf(w, "allocations %.1f allocs/msg, %.0f B/msg%s\n",
+		float64(mallocs)/float64(total), float64(bytes)/float64(total), note)
+}
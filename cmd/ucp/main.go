@@ -0,0 +1,326 @@
+// Command ucp sends, receives and decodes UCP (EMI) messages.
+//
+// Usage:
+//
+//	ucp send   [connection flags] [message flags] -sender S -receiver R [-billing-id ID] message...
+//	ucp listen [connection flags]
+//	ucp decode [-hex FRAME]
+//	ucp ping   [connection flags]
+//
+// Connection flags default to the UCP_ADDR, UCP_USER, UCP_PASSWORD and
+// UCP_ACCESS_CODE environment variables.
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gsm/ucp"
+)
+
+const usage = `usage: ucp <command> [flags]
+
+commands:
+  send    submit a short message
+  listen  print delivery notifications and mobile-originating messages as JSON lines
+  decode  pretty-print raw EMI frames read from stdin or given as hex
+  ping    login and do an alert round trip
+
+Run "ucp <command> -h" for the flags of a command.
+`
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("ucp: ")
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "send":
+		err = send(args, os.Stdout)
+	case "listen":
+		err = listen(args)
+	case "decode":
+		err = decode(args, os.Stdin, os.Stdout)
+	case "ping":
+		err = ping(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stdout, usage)
+	default:
+		fmt.Fprintf(os.Stderr, "ucp: unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// connConfig holds the connection settings shared by the commands that talk to an SMSC.
+type connConfig struct {
+	ucp.Options
+	verbose bool
+}
+
+// connFlags registers the connection flags on fs.
+func connFlags(fs *flag.FlagSet) *connConfig {
+	cfg := new(connConfig)
+	opt := &cfg.Options
+	fs.StringVar(&opt.Addr, "addr", os.Getenv("UCP_ADDR"), "SMSC `host:port` [$UCP_ADDR]")
+	fs.StringVar(&opt.User, "user", os.Getenv("UCP_USER"), "SMSC user [$UCP_USER]")
+	fs.StringVar(&opt.Password, "password", os.Getenv("UCP_PASSWORD"), "SMSC password [$UCP_PASSWORD]")
+	fs.StringVar(&opt.AccessCode, "access-code", os.Getenv("UCP_ACCESS_CODE"), "SMSC access code [$UCP_ACCESS_CODE]")
+	fs.DurationVar(&opt.Timeout, "timeout", 5*time.Second, "network timeout for responses")
+	fs.BoolVar(&cfg.verbose, "v", false, "log protocol debug output to stderr")
+	return cfg
+}
+
+// dial connects a client with the given settings.
+// setup, if not nil, is called before connecting.
+func dial(cfg *connConfig, setup func(*ucp.Client)) (*ucp.Client, error) {
+	if cfg.Addr == "" {
+		return nil, fmt.Errorf("missing SMSC address, set -addr or UCP_ADDR")
+	}
+	if cfg.verbose {
+		cfg.Logger = log.New(os.Stderr, "debug ", log.LstdFlags)
+	}
+	client := ucp.New(&cfg.Options)
+	if setup != nil {
+		setup(client)
+	}
+	if err := client.Connect(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// sendConfig holds the per-message flags of send.
+type sendConfig struct {
+	ucp.SendOptions
+	deliverAt     string
+	urgency       string
+	ackRequest    string
+	notifications string
+	originator    string
+}
+
+// sendFlags registers the per-message flags on fs.
+func sendFlags(fs *flag.FlagSet) *sendConfig {
+	cfg := new(sendConfig)
+	opt := &cfg.SendOptions
+	fs.StringVar(&opt.CorrelationID, "correlation-id", "", "ID saved with the message and reported with its delivery reports")
+	fs.BoolVar(&opt.Transliterate, "transliterate", false, "replace characters with GSM 7-bit equivalents when that avoids UCS-2")
+	fs.DurationVar(&opt.ValidityPeriod, "validity", 0, "validity period, 0 leaves it to the SMSC")
+	fs.StringVar(&cfg.deliverAt, "deliver-at", "", "deliver at an RFC 3339 `time`, or after a delay such as 2h")
+	fs.StringVar(&cfg.urgency, "urgency", "normal", "urgency: bulk, normal, urgent or very-urgent")
+	fs.StringVar(&cfg.ackRequest, "ack", "delivery", "acknowledgement asked from the handset: delivery, none, manual or delivery-and-manual")
+	fs.StringVar(&cfg.notifications, "notify", "delivered", "comma separated notifications: delivered, non-delivered, buffered, all or none")
+	fs.StringVar(&cfg.originator, "originator", "auto", "sender type: auto, alphanumeric, international, national or short-code")
+	return cfg
+}
+
+var urgencies = map[string]ucp.Urgency{
+	"bulk":        ucp.UrgencyBulk,
+	"normal":      ucp.UrgencyNormal,
+	"urgent":      ucp.UrgencyUrgent,
+	"very-urgent": ucp.UrgencyVeryUrgent,
+}
+
+var ackRequests = map[string]ucp.AckRequest{
+	"delivery":            ucp.AckDelivery,
+	"none":                ucp.AckNone,
+	"manual":              ucp.AckManual,
+	"delivery-and-manual": ucp.AckDeliveryAndManual,
+}
+
+var notifications = map[string]ucp.Notification{
+	"delivered":     ucp.NotifyDelivered,
+	"non-delivered": ucp.NotifyNonDelivered,
+	"buffered":      ucp.NotifyBuffered,
+	"all":           ucp.NotifyAll,
+}
+
+var originators = map[string]ucp.OriginatorType{
+	"auto":          ucp.OriginatorAuto,
+	"alphanumeric":  ucp.OriginatorAlphanumeric,
+	"international": ucp.OriginatorInternational,
+	"national":      ucp.OriginatorNational,
+	"short-code":    ucp.OriginatorShortCode,
+}
+
+// options returns the send options given by the flags, with delays counted from now.
+func (cfg *sendConfig) options(now time.Time) (*ucp.SendOptions, error) {
+	opt := cfg.SendOptions
+	if cfg.deliverAt != "" {
+		if delay, err := time.ParseDuration(cfg.deliverAt); err == nil {
+			opt.DeliverAt = now.Add(delay)
+		} else if opt.DeliverAt, err = time.Parse(time.RFC3339, cfg.deliverAt); err != nil {
+			return nil, fmt.Errorf("invalid -deliver-at %q, want an RFC 3339 time or a delay", cfg.deliverAt)
+		}
+	}
+	var ok bool
+	if opt.Urgency, ok = urgencies[cfg.urgency]; !ok {
+		return nil, fmt.Errorf("invalid -urgency %q", cfg.urgency)
+	}
+	if opt.AckRequest, ok = ackRequests[cfg.ackRequest]; !ok {
+		return nil, fmt.Errorf("invalid -ack %q", cfg.ackRequest)
+	}
+	if opt.Originator, ok = originators[cfg.originator]; !ok {
+		return nil, fmt.Errorf("invalid -originator %q", cfg.originator)
+	}
+	if cfg.notifications == "none" {
+		opt.Notifications = ucp.NotifyNone
+		return &opt, nil
+	}
+	for _, name := range strings.Split(cfg.notifications, ",") {
+		n, ok := notifications[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("invalid -notify %q", name)
+		}
+		opt.Notifications |= n
+	}
+	return &opt, nil
+}
+
+func send(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	cfg := connFlags(fs)
+	msgCfg := sendFlags(fs)
+	sender := fs.String("sender", "", "sender mask")
+	receiver := fs.String("receiver", "", "receiver MSISDN")
+	billingID := fs.String("billing-id", "", "billing identifier")
+	fs.IntVar(&cfg.Tps, "tps", 10, "mobile-terminating transactions per second")
+	fs.Parse(args)
+	message := strings.Join(fs.Args(), " ")
+	if *sender == "" || *receiver == "" || message == "" {
+		return fmt.Errorf("send needs -sender, -receiver and a message")
+	}
+	opt, err := msgCfg.options(time.Now())
+	if err != nil {
+		return err
+	}
+	client, err := dial(cfg, nil)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	client.SetBillingID(*billingID)
+	result, err := client.SendWithOptions(*sender, *receiver, message, opt)
+	for _, sub := range result.Substitutions {
+		log.Printf("transliterated %q to %q at offset %d", sub.From, sub.To, sub.Offset)
+	}
+	for _, id := range result.IDs {
+		if id != "" {
+			fmt.Fprintln(stdout, id)
+		}
+	}
+	return err
+}
+
+// event is a delivery notification or a mobile-originating message printed by listen.
+type event struct {
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	Sender     string    `json:"sender"`
+	Receiver   string    `json:"receiver"`
+	MessageID  string    `json:"message_id"`
+	Message    string    `json:"message"`
+	AccessCode string    `json:"access_code,omitempty"`
+}
+
+func listen(args []string) error {
+	fs := flag.NewFlagSet("listen", flag.ExitOnError)
+	cfg := connFlags(fs)
+	fs.Parse(args)
+
+	var mu sync.Mutex
+	enc := json.NewEncoder(os.Stdout)
+	handler := func(typ string) ucp.Handler {
+		return func(sender, receiver, messageID, message, accessCode string) {
+			mu.Lock()
+			defer mu.Unlock()
+			enc.Encode(event{typ, time.Now(), sender, receiver, messageID, message, accessCode})
+		}
+	}
+	client, err := dial(cfg, func(c *ucp.Client) {
+		c.DeliveryHandler(handler("dr"))
+		c.ShortMessageHandler(handler("mo"))
+	})
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	<-sig
+	return nil
+}
+
+func ping(args []string) error {
+	fs := flag.NewFlagSet("ping", flag.ExitOnError)
+	cfg := connFlags(fs)
+	fs.Parse(args)
+	start := time.Now()
+	client, err := dial(cfg, nil)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	loggedIn := time.Now()
+	if err := client.Ping(); err != nil {
+		return err
+	}
+	fmt.Printf("login %v, alert round trip %v\n", loggedIn.Sub(start), time.Since(loggedIn))
+	return nil
+}
+
+func decode(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	hexFrame := fs.String("hex", "", "hex encoded frame to decode instead of reading stdin")
+	fs.Parse(args)
+
+	if *hexFrame != "" {
+		frame, err := hex.DecodeString(*hexFrame)
+		if err != nil {
+			return err
+		}
+		printFrame(stdout, frame)
+		return nil
+	}
+	reader := bufio.NewReader(stdin)
+	for {
+		frame, err := reader.ReadBytes('\x03')
+		if len(strings.TrimSpace(string(frame))) > 0 {
+			printFrame(stdout, frame)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// printFrame prints the dissected frame.
+func printFrame(w io.Writer, frame []byte) {
+	pdu, err := ucp.Describe(frame)
+	if err != nil {
+		fmt.Fprintf(w, "%q: %v\n", frame, err)
+		return
+	}
+	fmt.Fprintln(w, pdu)
+}
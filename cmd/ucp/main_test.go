@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gsm/ucp"
+	"github.com/go-gsm/ucp/ucptest"
+)
+
+const (
+	submitFrame = "\x0201/00126/O/51/09495696599/0ED6773E7C2ECB1B//1//1/////////////3/88/48656C6C6F20776F726C64////1////5039//020100060101070101///47\x03"
+	loginFrame  = "\x0200/00037/R/60/A/BIND AUTHENTICATED/6D\x03"
+)
+
+func TestDecodeStdin(t *testing.T) {
+	var out bytes.Buffer
+	in := strings.NewReader(submitFrame + "\n" + loginFrame + "\n")
+	if err := decode(nil, in, &out); err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	expected := []string{"Submit Short Message", `"Hello world"`, `"Voyager"`, "Session Management", "BIND AUTHENTICATED"}
+	for _, s := range expected {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("Expected output to contain %q, got %v\n", s, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "!!") {
+		t.Errorf("Expected no problems, got %v\n", out.String())
+	}
+}
+
+func TestDecodeHex(t *testing.T) {
+	var out bytes.Buffer
+	frame := []byte(submitFrame)
+	hexFrame := strings.ToUpper(hex.EncodeToString(frame))
+	if err := decode([]string{"-hex", hexFrame}, strings.NewReader(loginFrame), &out); err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	if !strings.Contains(out.String(), `"Hello world"`) {
+		t.Errorf("Expected output to contain %q, got %v\n", `"Hello world"`, out.String())
+	}
+	if strings.Contains(out.String(), "BIND AUTHENTICATED") {
+		t.Errorf("Expected stdin to be ignored, got %v\n", out.String())
+	}
+	if err := decode([]string{"-hex", "0G"}, strings.NewReader(""), &out); err == nil {
+		t.Errorf("Expected an error for invalid hex\n")
+	}
+}
+
+func TestDecodeInvalidFrame(t *testing.T) {
+	var out bytes.Buffer
+	if err := decode(nil, strings.NewReader("\x02garbage\x03"), &out); err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	if !strings.Contains(out.String(), `"\x02garbage\x03"`) {
+		t.Errorf("Expected the frame to be quoted with its error, got %v\n", out.String())
+	}
+}
+
+func TestSendOptions(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	sendOptionsTestCases := []struct {
+		name     string
+		args     []string
+		expected ucp.SendOptions
+		err      bool
+	}{
+		{
+			"defaults",
+			nil,
+			ucp.SendOptions{Notifications: ucp.NotifyDelivered},
+			false,
+		},
+		{
+			"every flag",
+			[]string{"-correlation-id", "order-1", "-transliterate", "-validity", "2h",
+				"-deliver-at", "2026-10-20T08:00:00Z", "-urgency", "very-urgent", "-ack", "delivery-and-manual",
+				"-notify", "delivered,buffered", "-originator", "short-code"},
+			ucp.SendOptions{
+				CorrelationID:  "order-1",
+				Transliterate:  true,
+				ValidityPeriod: 2 * time.Hour,
+				DeliverAt:      time.Date(2026, 10, 20, 8, 0, 0, 0, time.UTC),
+				Urgency:        ucp.UrgencyVeryUrgent,
+				AckRequest:     ucp.AckDeliveryAndManual,
+				Notifications:  ucp.NotifyDelivered | ucp.NotifyBuffered,
+				Originator:     ucp.OriginatorShortCode,
+			},
+			false,
+		},
+		{
+			"delayed delivery without notifications",
+			[]string{"-deliver-at", "90m", "-notify", "none", "-originator", "national"},
+			ucp.SendOptions{
+				DeliverAt:     now.Add(90 * time.Minute),
+				Notifications: ucp.NotifyNone,
+				Originator:    ucp.OriginatorNational,
+			},
+			false,
+		},
+		{"invalid deliver-at", []string{"-deliver-at", "tomorrow"}, ucp.SendOptions{}, true},
+		{"invalid urgency", []string{"-urgency", "asap"}, ucp.SendOptions{}, true},
+		{"invalid ack", []string{"-ack", "read"}, ucp.SendOptions{}, true},
+		{"invalid notification", []string{"-notify", "delivered,read"}, ucp.SendOptions{}, true},
+		{"invalid originator", []string{"-originator", "email"}, ucp.SendOptions{}, true},
+	}
+	for _, testCase := range sendOptionsTestCases {
+		fs := flag.NewFlagSet("send", flag.ContinueOnError)
+		cfg := sendFlags(fs)
+		if err := fs.Parse(testCase.args); err != nil {
+			t.Fatalf("%s: Expected nil error, got %v\n", testCase.name, err)
+		}
+		opt, err := cfg.options(now)
+		if testCase.err {
+			if err == nil {
+				t.Errorf("%s: Expected an error, got %+v\n", testCase.name, opt)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Expected nil error, got %v\n", testCase.name, err)
+			continue
+		}
+		if !opt.DeliverAt.Equal(testCase.expected.DeliverAt) {
+			t.Errorf("%s: Expected %v, got %v\n", testCase.name, testCase.expected.DeliverAt, opt.DeliverAt)
+		}
+		opt.DeliverAt, testCase.expected.DeliverAt = time.Time{}, time.Time{}
+		if *opt != testCase.expected {
+			t.Errorf("%s: Expected %+v, got %+v\n", testCase.name, testCase.expected, *opt)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	server := ucptest.NewServer("emi_client", "password")
+	defer server.Close()
+
+	var out bytes.Buffer
+	args := []string{"-addr", server.Addr, "-user", "emi_client", "-password", "password", "-timeout", "1s",
+		"-sender", "Voyager", "-receiver", "09191234567", "-transliterate", "-notify", "none", "-urgency", "urgent",
+		"“hello”", "world"}
+	if err := send(args, &out); err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	submissions := server.Submissions()
+	if len(submissions) != 1 {
+		t.Fatalf("Expected 1 submission, got %v\n", len(submissions))
+	}
+	sub := submissions[0]
+	if sub.Text != `"hello" world` {
+		t.Errorf("Expected %v, got %v\n", `"hello" world`, sub.Text)
+	}
+	if out.String() != sub.ID+"\n" {
+		t.Errorf("Expected %q, got %q\n", sub.ID+"\n", out.String())
+	}
+	// NRq is 0 and the Xser holds an urgency indicator of 02
+	if sub.Fields[3] != "0" {
+		t.Errorf("Expected NRq %v, got %v\n", "0", sub.Fields[3])
+	}
+	if !strings.Contains(sub.Fields[30], "060102") {
+		t.Errorf("Expected Xser to contain %v, got %v\n", "060102", sub.Fields[30])
+	}
+}
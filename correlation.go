@@ -0,0 +1,272 @@
+package ucp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// CorrelationStore maps the SMSC message IDs of sent messages to the
+// correlation IDs given by the caller, until their final delivery report arrives.
+// IDs of messages whose final report never arrives are kept until the caller removes them,
+// with Prune for the stores of this package.
+type CorrelationStore interface {
+	// Save records the correlation ID of a message ID.
+	Save(messageID, correlationID string) error
+	// Load returns the correlation ID of a message ID, if any.
+	Load(messageID string) (correlationID string, ok bool, err error)
+	// Delete forgets a message ID.
+	Delete(messageID string) error
+}
+
+// MemoryCorrelationStore is a CorrelationStore that keeps the IDs in memory.
+type MemoryCorrelationStore struct {
+	mu  sync.Mutex
+	ids map[string]correlationEntry
+}
+
+// correlationEntry is a correlation ID with the time it was saved.
+type correlationEntry struct {
+	correlationID string
+	saved         time.Time
+}
+
+// NewMemoryCorrelationStore returns an empty MemoryCorrelationStore.
+func NewMemoryCorrelationStore() *MemoryCorrelationStore {
+	return &MemoryCorrelationStore{ids: make(map[string]correlationEntry)}
+}
+
+// Save implements CorrelationStore.
+func (s *MemoryCorrelationStore) Save(messageID, correlationID string) error {
+	s.save(messageID, correlationEntry{correlationID, time.Now()})
+	return nil
+}
+
+func (s *MemoryCorrelationStore) save(messageID string, entry correlationEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids[messageID] = entry
+}
+
+// Load implements CorrelationStore.
+func (s *MemoryCorrelationStore) Load(messageID string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.ids[messageID]
+	return entry.correlationID, ok, nil
+}
+
+// Delete implements CorrelationStore.
+func (s *MemoryCorrelationStore) Delete(messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.ids, messageID)
+	return nil
+}
+
+// Prune forgets the IDs saved before the given time and returns how many were removed.
+// Messages sent without notifications, or whose report is lost, are otherwise kept forever.
+func (s *MemoryCorrelationStore) Prune(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for messageID, entry := range s.ids {
+		if entry.saved.Before(before) {
+			delete(s.ids, messageID)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// entries returns a copy of the stored IDs.
+func (s *MemoryCorrelationStore) entries() map[string]correlationEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make(map[string]correlationEntry, len(s.ids))
+	for messageID, entry := range s.ids {
+		entries[messageID] = entry
+	}
+	return entries
+}
+
+// FileCorrelationStore is a CorrelationStore that survives restarts.
+// Changes are appended to a file as JSON lines, the file is compacted when opened and pruned.
+type FileCorrelationStore struct {
+	mem  *MemoryCorrelationStore
+	path string
+	// mu guards file, and keeps it in step with mem
+	mu   sync.Mutex
+	file *os.File
+}
+
+// correlationRecord is a line of the file of a FileCorrelationStore.
+type correlationRecord struct {
+	MessageID     string    `json:"message_id"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	Saved         time.Time `json:"saved"`
+	Deleted       bool      `json:"deleted,omitempty"`
+}
+
+// OpenFileCorrelationStore opens the store kept in the file at path, creating it if needed.
+// The caller should call Close when finished.
+func OpenFileCorrelationStore(path string) (*FileCorrelationStore, error) {
+	s := &FileCorrelationStore{mem: NewMemoryCorrelationStore(), path: path}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// open opens the file for appending.
+func (s *FileCorrelationStore) open() error {
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	s.file = file
+	return nil
+}
+
+// load replays the records of the file into memory.
+func (s *FileCorrelationStore) load() error {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	// records written before saved times were kept count as saved when loaded
+	now := time.Now()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record correlationRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// skip a line torn by a crash
+			continue
+		}
+		if record.Deleted {
+			delete(s.mem.ids, record.MessageID)
+			continue
+		}
+		if record.Saved.IsZero() {
+			record.Saved = now
+		}
+		s.mem.ids[record.MessageID] = correlationEntry{record.CorrelationID, record.Saved}
+	}
+	return scanner.Err()
+}
+
+// compact rewrites the file with the live records only.
+func (s *FileCorrelationStore) compact() error {
+	tmp := s.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for messageID, entry := range s.mem.entries() {
+		record := correlationRecord{MessageID: messageID, CorrelationID: entry.correlationID, Saved: entry.saved}
+		if err := writeCorrelationRecord(writer, record); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func writeCorrelationRecord(w io.Writer, record correlationRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// append writes a record to the file and syncs it to disk. s.mu must be held.
+func (s *FileCorrelationStore) append(record correlationRecord) error {
+	if err := writeCorrelationRecord(s.file, record); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Save implements CorrelationStore.
+func (s *FileCorrelationStore) Save(messageID, correlationID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved := time.Now()
+	if err := s.append(correlationRecord{MessageID: messageID, CorrelationID: correlationID, Saved: saved}); err != nil {
+		return err
+	}
+	s.mem.save(messageID, correlationEntry{correlationID, saved})
+	return nil
+}
+
+// Load implements CorrelationStore.
+func (s *FileCorrelationStore) Load(messageID string) (string, bool, error) {
+	return s.mem.Load(messageID)
+}
+
+// Delete implements CorrelationStore.
+func (s *FileCorrelationStore) Delete(messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok, _ := s.mem.Load(messageID); !ok {
+		return nil
+	}
+	if err := s.append(correlationRecord{MessageID: messageID, Deleted: true}); err != nil {
+		return err
+	}
+	return s.mem.Delete(messageID)
+}
+
+// Prune forgets the IDs saved before the given time and returns how many were removed.
+// The file is rewritten without them.
+func (s *FileCorrelationStore) Prune(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, _ := s.mem.Prune(before)
+	if n == 0 {
+		return 0, nil
+	}
+	if err := s.file.Close(); err != nil {
+		return n, err
+	}
+	err := s.compact()
+	// keep appending to the old file if it could not be rewritten
+	if openErr := s.open(); err == nil {
+		err = openErr
+	}
+	return n, err
+}
+
+// Close closes the file of the store.
+func (s *FileCorrelationStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
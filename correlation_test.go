@@ -0,0 +1,160 @@
+package ucp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileCorrelationStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ucp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "correlation")
+
+	store, err := OpenFileCorrelationStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Save("09191234567:110917160250", "order-1")
+	store.Save("09191234567:110917160251", "order-2")
+	store.Delete("09191234567:110917160250")
+	store.Close()
+
+	// reopening restores the live IDs only
+	store, err = OpenFileCorrelationStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	testcases := []struct {
+		messageID     string
+		correlationID string
+		ok            bool
+	}{
+		{"09191234567:110917160250", "", false},
+		{"09191234567:110917160251", "order-2", true},
+	}
+	for _, tc := range testcases {
+		correlationID, ok, err := store.Load(tc.messageID)
+		if err != nil || ok != tc.ok || correlationID != tc.correlationID {
+			t.Errorf("Load(%q): expected %q %v, got %q %v %v", tc.messageID, tc.correlationID, tc.ok, correlationID, ok, err)
+		}
+	}
+}
+
+func TestCorrelationStorePrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ucp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "correlation")
+	fileStore, err := OpenFileCorrelationStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testcases := []struct {
+		name  string
+		store interface {
+			CorrelationStore
+			Prune(time.Time) (int, error)
+		}
+	}{
+		{"memory", NewMemoryCorrelationStore()},
+		{"file", fileStore},
+	}
+	for _, tc := range testcases {
+		tc.store.Save("09191234567:110917160250", "order-1")
+		before := time.Now().Add(time.Millisecond)
+		time.Sleep(2 * time.Millisecond)
+		tc.store.Save("09191234567:110917160251", "order-2")
+		if n, err := tc.store.Prune(before); n != 1 || err != nil {
+			t.Errorf("%s: expected 1 pruned ID, got %d %v", tc.name, n, err)
+		}
+		if _, ok, _ := tc.store.Load("09191234567:110917160250"); ok {
+			t.Errorf("%s: expected the old ID to be pruned", tc.name)
+		}
+		if correlationID, ok, _ := tc.store.Load("09191234567:110917160251"); !ok || correlationID != "order-2" {
+			t.Errorf("%s: expected the new ID to be kept, got %q %v", tc.name, correlationID, ok)
+		}
+	}
+
+	// the pruned file keeps the saved times and accepts new records
+	fileStore.Save("09191234567:110917160252", "order-3")
+	fileStore.Close()
+	reopened, err := OpenFileCorrelationStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	for messageID, expected := range map[string]string{"09191234567:110917160250": "", "09191234567:110917160251": "order-2", "09191234567:110917160252": "order-3"} {
+		if correlationID, _, _ := reopened.Load(messageID); correlationID != expected {
+			t.Errorf("Load(%q): expected %q, got %q", messageID, expected, correlationID)
+		}
+	}
+	if n, _ := reopened.Prune(time.Now().Add(time.Millisecond)); n != 2 {
+		t.Errorf("Expected 2 pruned IDs after reopening, got %d", n)
+	}
+}
+
+func TestFileCorrelationStoreConcurrentPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ucp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "correlation")
+	store, err := OpenFileCorrelationStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Save("stale", "order-0")
+	before := time.Now().Add(time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+
+	// saves and deletes racing with prunes must all reach the file
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				id := fmt.Sprintf("%d:%d", i, j)
+				store.Save(id, "order")
+				if j%2 == 1 {
+					store.Delete(id)
+				}
+			}
+		}(i)
+	}
+	for k := 0; k < 20; k++ {
+		store.Prune(before)
+	}
+	wg.Wait()
+	store.Close()
+
+	reopened, err := OpenFileCorrelationStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if _, ok, _ := reopened.Load("stale"); ok {
+		t.Errorf("Expected the stale ID to be pruned")
+	}
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 20; j++ {
+			id := fmt.Sprintf("%d:%d", i, j)
+			if _, ok, _ := reopened.Load(id); ok != (j%2 == 0) {
+				t.Errorf("Load(%q): expected %v, got %v", id, j%2 == 0, ok)
+			}
+		}
+	}
+}
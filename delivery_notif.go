@@ -3,9 +3,87 @@ package ucp
 import (
 	"bufio"
 	"encoding/hex"
+	"strconv"
 	"sync"
 )
 
+// DeliveryStatus is the status of a delivered message (Dst).
+type DeliveryStatus int
+
+const (
+	// StatusDelivered means the message reached the handset.
+	StatusDelivered DeliveryStatus = iota
+	// StatusBuffered means the message is waiting in the SMSC, a final report follows.
+	StatusBuffered
+	// StatusNotDelivered means the message failed permanently.
+	StatusNotDelivered
+)
+
+func (s DeliveryStatus) String() string {
+	switch s {
+	case StatusDelivered:
+		return "delivered"
+	case StatusBuffered:
+		return "buffered"
+	case StatusNotDelivered:
+		return "not delivered"
+	}
+	return "DeliveryStatus(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Final reports whether no further report follows a report with this status.
+func (s DeliveryStatus) Final() bool {
+	return s != StatusBuffered
+}
+
+// DeliveryReport is a delivery notification received from the SMSC.
+type DeliveryReport struct {
+	// MessageID is the ID of the reported message, as returned by Send.
+	MessageID string
+	// Sender is the originator of the reported message.
+	Sender string
+	// Receiver is the recipient of the reported message.
+	Receiver string
+	// Status is the delivery status.
+	Status DeliveryStatus
+	// Reason is the reason code (Rsn) given by the SMSC.
+	Reason string
+	// SubmitTime is the service centre time stamp of the reported message.
+	SubmitTime string
+	// DeliveryTime is the time stamp of the status (DSCTS).
+	DeliveryTime string
+	// Message is the notification text.
+	Message string
+	// CorrelationID is the correlation ID the message was sent with, if any.
+	CorrelationID string
+	// XSer are the extra services of the notification, up to an invalid entry.
+	XSer XSer
+}
+
+// ReportHandler is called with every delivery notification received from the SMSC.
+type ReportHandler func(report DeliveryReport)
+
+// newDeliveryReport parses the fields of a delivery notification.
+func newDeliveryReport(dr []string) DeliveryReport {
+	msg, _ := hex.DecodeString(dr[drMsgIndex])
+	xser, _ := ParseXSer(xserField(dr))
+	status, err := strconv.Atoi(dr[drDstIndex])
+	if err != nil {
+		status = int(StatusNotDelivered)
+	}
+	return DeliveryReport{
+		MessageID:    dr[drRecvrIndex] + ":" + dr[drSctsIndex],
+		Sender:       dr[drSenderIndex],
+		Receiver:     decodeOriginator(dr[drRecvrIndex], otoaField(dr)),
+		Status:       DeliveryStatus(status),
+		Reason:       dr[drRsnIndex],
+		SubmitTime:   dr[drSctsIndex],
+		DeliveryTime: dr[drDsctsIndex],
+		Message:      string(msg),
+		XSer:         xser,
+	}
+}
+
 type deliveryNotification struct {
 	AdC   []byte
 	OAdC  []byte
@@ -59,9 +137,11 @@ func (s deliveryNotifAck) Type() []byte {
 
 // readDeliveryNotif reads all deliver notifications from deliverNotifCh channel.
 // Once a deliver notification message is read, it sends an ack to the SMSC and
-// calls deliveryHandler.
+// calls deliveryHandler and reportHandler, if set.
+// The correlation ID of the message is looked up in store, and forgotten once the report is final.
 func readDeliveryNotif(writer *bufio.Writer, wg *sync.WaitGroup, closeChan chan struct{},
-	deliverNotifCh chan []string, deliveryHandler Handler, accessCode string, mu *sync.Mutex, logger Logger) {
+	deliverNotifCh chan []string, deliveryHandler Handler, reportHandler ReportHandler, accessCode string,
+	store CorrelationStore, mu *sync.Mutex, hook FrameHook, logger Logger) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -72,20 +152,38 @@ func readDeliveryNotif(writer *bufio.Writer, wg *sync.WaitGroup, closeChan chan
 				return
 			case dr := <-deliverNotifCh:
 				refNum := dr[refNumIndex]
-				msg, _ := hex.DecodeString(dr[drMsgIndex])
-				sender := dr[drSenderIndex]
-				recvr := dr[drRecvrIndex]
-				scts := dr[drSctsIndex]
-				msgID := recvr + ":" + scts
+				report := newDeliveryReport(dr)
+				msgID := report.MessageID
+				if store != nil {
+					correlationID, ok, err := store.Load(msgID)
+					if err != nil {
+						logger.Printf("error loading correlationID of %v: %v\n", msgID, err)
+					}
+					if ok {
+						report.CorrelationID = correlationID
+						logger.Printf("delivery notification %v correlationID: %q status: %v\n", msgID, correlationID, report.Status)
+						if report.Status.Final() {
+							if err := store.Delete(msgID); err != nil {
+								logger.Printf("error deleting correlationID of %v: %v\n", msgID, err)
+							}
+						}
+					}
+				}
 				mu.Lock()
-				if _, err := writer.Write(deliveryNotifAckPacket([]byte(refNum), msgID)); err != nil {
+				ackPacket := deliveryNotifAckPacket([]byte(refNum), msgID)
+				if _, err := writer.Write(ackPacket); err != nil {
 					logger.Printf("error writing delivery notification ack packet: %v\n", err)
 				}
 				if err := writer.Flush(); err != nil {
 					logger.Printf("error flushing delivery notification ack packet: %v\n", err)
+				} else {
+					hook.trace(Outbound, ackPacket)
 				}
 				mu.Unlock()
-				deliveryHandler(sender, recvr, msgID, string(msg), accessCode)
+				deliveryHandler(report.Sender, report.Receiver, msgID, report.Message, accessCode)
+				if reportHandler != nil {
+					reportHandler(report)
+				}
 			}
 		}
 	}()
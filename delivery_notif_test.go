@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"reflect"
 	"runtime"
 	"sync"
 	"testing"
@@ -46,8 +47,8 @@ func TestReadDeliveryNotif(t *testing.T) {
 		muconn: &sync.Mutex{},
 		logger: log.New(os.Stdout, "debug ", 0),
 	}
-	readDeliveryNotif(writer, wg, closeChan, deliverNotifCh, f, "",
-		client.muconn, client)
+	readDeliveryNotif(writer, wg, closeChan, deliverNotifCh, f, nil, "", nil,
+		client.muconn, nil, client)
 	runtime.Gosched()
 	deliverNotifCh <- []string{"00", "00304", "O", "53", "2371", "09191234567", "", "", "", "", "", "", "", "", "", "", "", "", "110917160250", "0", "000", "110917160252", "3", "", "4D65737361676520666F72202B3633393139313233343536372C2077697468206964656E74696669636174696F6E2031373039313131363032353020686173206265656E2064656C697665726564206F6E20323031372D30392D31312061742031363A30323A35322E", "1", "", "", "", "", "", "", "", "", "", "", "", "91"}
 
@@ -61,3 +62,66 @@ func TestReadDeliveryNotif(t *testing.T) {
 	}
 
 }
+
+func TestNewDeliveryReport(t *testing.T) {
+	dr := []string{"00", "00304", "O", "53", "2371", "09191234567", "", "", "", "", "", "", "", "", "", "", "", "", "110917160250", "1", "108", "110917160252", "3", "", "4D657373616765", "1", "", "", "", "", "", "", "", "", "020100", "", "", "91"}
+	expected := DeliveryReport{
+		MessageID:    "09191234567:110917160250",
+		Sender:       "2371",
+		Receiver:     "09191234567",
+		Status:       StatusBuffered,
+		Reason:       "108",
+		SubmitTime:   "110917160250",
+		DeliveryTime: "110917160252",
+		Message:      "Message",
+		XSer:         XSer{{XSerGSMDCS, []byte{0x00}}},
+	}
+	if actual := newDeliveryReport(dr); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %+v, got %+v\n", expected, actual)
+	}
+}
+
+func TestNewDeliveryReportAlphanumeric(t *testing.T) {
+	dr := []string{"00", "00304", "O", "53", "2371", maskSender("Voyager"), "", "", "", "", "", "", "", "", "", "", "", "", "110917160250", "0", "000", "110917160252", "3", "", "", "", "", "", "", "", "", "", oAdCAlphaNum, "", "", "", "", "91"}
+	report := newDeliveryReport(dr)
+	if report.Receiver != "Voyager" {
+		t.Errorf("Expected Voyager, got %q\n", report.Receiver)
+	}
+	if expected := maskSender("Voyager") + ":110917160250"; report.MessageID != expected {
+		t.Errorf("Expected the message ID %s, got %s\n", expected, report.MessageID)
+	}
+}
+
+func TestReadDeliveryNotifCorrelation(t *testing.T) {
+	wg := new(sync.WaitGroup)
+	closeChan := make(chan struct{})
+	deliverNotifCh := make(chan []string)
+	reports := make(chan DeliveryReport, 1)
+	store := NewMemoryCorrelationStore()
+	store.Save("09191234567:110917160250", "order-42")
+	client := &Client{muconn: &sync.Mutex{}}
+	readDeliveryNotif(bufio.NewWriter(new(bytes.Buffer)), wg, closeChan, deliverNotifCh, DefaultHandler,
+		func(report DeliveryReport) { reports <- report }, "", store, client.muconn, nil, client)
+
+	dr := []string{"00", "00304", "O", "53", "2371", "09191234567", "", "", "", "", "", "", "", "", "", "", "", "", "110917160250", "1", "108", "110917160252", "3", "", "", "1", "", "", "", "", "", "", "", "", "", "", "", "91"}
+	testcases := []struct {
+		status DeliveryStatus
+		kept   bool
+	}{
+		{StatusBuffered, true},
+		{StatusDelivered, false},
+	}
+	for _, tc := range testcases {
+		dr[drDstIndex] = fmt.Sprint(int(tc.status))
+		deliverNotifCh <- append([]string(nil), dr...)
+		report := <-reports
+		if report.CorrelationID != "order-42" || report.Status != tc.status {
+			t.Errorf("Expected correlation ID order-42 with status %v, got %+v\n", tc.status, report)
+		}
+		if _, ok, _ := store.Load(report.MessageID); ok != tc.kept {
+			t.Errorf("Expected correlation ID kept %v after %v report\n", tc.kept, tc.status)
+		}
+	}
+	close(closeChan)
+	wg.Wait()
+}
@@ -4,9 +4,8 @@ import (
 	"bufio"
 	"encoding/hex"
 	"fmt"
-	"sort"
-	"strconv"
 	"sync"
+	"time"
 
 	"github.com/go-gsm/charset"
 )
@@ -72,9 +71,37 @@ func deliverySmAckPacket(refNum []byte, systemMessage string) []byte {
 	return buf
 }
 
+// deliverySmNack represents a data structure of a negative acknowledgment packet for deliver sm.
+type deliverySmNack struct {
+	Nack          []byte
+	ErrorCode     []byte
+	SystemMessage []byte
+}
+
+func (s deliverySmNack) Code() []byte {
+	return []byte(opDeliveryShortMessage)
+}
+
+func (s deliverySmNack) Type() []byte {
+	return []byte(resultType)
+}
+
+// deliverySmNackPacket builds a negative deliverySmAck packet, so that the SMSC delivers the message again
+func deliverySmNackPacket(refNum []byte, errCode, systemMessage string) []byte {
+	nack := deliverySmNack{
+		Nack:          []byte(negativeAck),
+		ErrorCode:     []byte(errCode),
+		SystemMessage: []byte(systemMessage),
+	}
+	return preparePacket(refNum, nack)
+}
+
 // readDeliveryMsg reads all deliver sm messages(mobile-originating messages) from the deliverMsgCh channel.
+// The parts of multipart messages are added to store before they are acknowledged,
+// a part that cannot be stored is negatively acknowledged for the SMSC to deliver it again.
 func readDeliveryMsg(writer *bufio.Writer, wg *sync.WaitGroup, closeChan chan struct{},
-	deliverMsgCh chan []string, deliverMsgPartCh, deliverMsgCompleteCh chan deliverMsgPart, mu *sync.Mutex, logger Logger) {
+	deliverMsgCh chan []string, deliverMsgPartCh, deliverMsgCompleteCh chan deliverMsgPart, store ReassemblyStore,
+	mu *sync.Mutex, hook FrameHook, logger Logger) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -84,63 +111,73 @@ func readDeliveryMsg(writer *bufio.Writer, wg *sync.WaitGroup, closeChan chan st
 				logger.Printf("readDeliveryMsg terminated\n")
 				return
 			case mo := <-deliverMsgCh:
-				xser := mo[xserIndex]
-				xserData := parseXser(xser)
+				xser, err := ParseXSer(mo[xserIndex])
+				if err != nil {
+					logger.Printf("error parsing extra services %v: %v\n", mo[xserIndex], err)
+				}
 				msg := mo[moMsgIndex]
 				refNum := mo[refNumIndex]
-				sender := mo[moSenderIndex]
+				sender := decodeOriginator(mo[moSenderIndex], otoaField(mo))
 				recvr := mo[moRecvrIndex]
 				scts := mo[moSctsIndex]
 				sysmsg := recvr + ":" + scts
 				msgID := sender + ":" + scts
 
+				var incomingMsg deliverMsgPart
+				incomingMsg.sender = sender
+				incomingMsg.receiver = recvr
+				incomingMsg.message = msg
+				incomingMsg.msgID = msgID
+				incomingMsg.dcs = xser.dataCoding()
+
+				// check the user data header extra service field
+				// if it has a concatenation information element, the incoming message has multiple parts
+				var multipart bool
+				if udh, ok, err := xser.UDH(); ok {
+					if err != nil {
+						logger.Printf("error parsing user data header of %v: %v\n", msgID, err)
+					}
+					incomingMsg.shift = shiftFromUDH(udh)
+					// handle multipart mobile originating message i.e. len(message) > 140 bytes
+					incomingMsg.refNum, incomingMsg.totalParts, incomingMsg.currentPart, multipart = udh.Concat()
+				}
+
+				// store the part before acknowledging it, so that a restart does not lose it
+				forward := true
+				ackPacket := deliverySmAckPacket([]byte(refNum), sysmsg)
+				if multipart {
+					added, err := store.Add(incomingMsg.key(), incomingMsg.messagePart(time.Now()))
+					if err != nil {
+						logger.Printf("error storing part %d of %v: %v\n", incomingMsg.currentPart, incomingMsg.key(), err)
+						ackPacket = deliverySmNackPacket([]byte(refNum), errCodeNotAllowed, "part not stored")
+					} else if !added {
+						logger.Printf("ignoring duplicate part %d of %v\n", incomingMsg.currentPart, incomingMsg.key())
+					}
+					forward = added && err == nil
+				}
+
 				mu.Lock()
 				// send ack to SMSC with the same reference number
-				if _, err := writer.Write(deliverySmAckPacket([]byte(refNum), sysmsg)); err != nil {
+				if _, err := writer.Write(ackPacket); err != nil {
 					logger.Printf("error writing delivery sm ack packet: %v\n", err)
 				}
 				if err := writer.Flush(); err != nil {
 					logger.Printf("error flushing delivery sm ack packet: %v\n", err)
+				} else {
+					hook.trace(Outbound, ackPacket)
 				}
 				mu.Unlock()
 
-				var incomingMsg deliverMsgPart
-				incomingMsg.sender = sender
-				incomingMsg.receiver = recvr
-				incomingMsg.message = msg
-				incomingMsg.msgID = msgID
-
-				if xserDCS, ok := xserData[dcsXserKey]; ok {
-					incomingMsg.dcs = xserDCS
+				if !forward {
+					continue
 				}
-
-				// check the user data header extra service field
-				// if it exists, the incoming message has multiple parts
-				if xserUdh, ok := xserData[udhXserKey]; ok {
-					// handle multipart mobile originating message i.e. len(message) > 140 bytes
-					// get the total message parts in the xser data
-					msgPartsLen := xserUdh[len(xserUdh)-4 : len(xserUdh)-2]
-					// get the current message part in the xser data
-					msgPart := xserUdh[len(xserUdh)-2:]
-					// get UDH reference number
-					msgRefNum := xserUdh[len(xserUdh)-6 : len(xserUdh)-4]
-					// convert hexstring to integer
-					msgRefNumInt, _ := strconv.ParseInt(msgRefNum, 16, 0)
-					msgPartsLenInt, _ := strconv.ParseInt(msgPartsLen, 16, 64)
-					msgPartInt, _ := strconv.ParseInt(msgPart, 16, 64)
-					// convert int64 to int
-					incomingMsg.currentPart = int(msgPartInt)
-					incomingMsg.totalParts = int(msgPartsLenInt)
-					incomingMsg.refNum = int(msgRefNumInt)
+				if multipart {
 					// send to partial channel
-
 					deliverMsgPartCh <- incomingMsg
-
 				} else {
 					// handle mobile originating message with only 1 part i.e. len(message) <= 140 bytes
 					// send the incoming message to the complete channel
 					deliverMsgCompleteCh <- incomingMsg
-
 				}
 			}
 
@@ -149,47 +186,176 @@ func readDeliveryMsg(writer *bufio.Writer, wg *sync.WaitGroup, closeChan chan st
 }
 
 // readPartialDeliveryMsg concatenates partial incoming mobile-originating messages
+// from the parts added to store by readDeliveryMsg.
+// Messages still missing parts after timeout, or evicted to keep at most maxPending
+// messages pending, are passed on with the numbers of their missing parts.
+// A zero timeout or maxPending disables the limit.
 func readPartialDeliveryMsg(wg *sync.WaitGroup, closeChan chan struct{},
-	deliverMsgPartCh, deliverMsgCompleteCh chan deliverMsgPart, logger Logger) {
+	deliverMsgPartCh, deliverMsgCompleteCh chan deliverMsgPart, store ReassemblyStore,
+	timeout time.Duration, maxPending int, logger Logger) {
+	// seen holds the messages this goroutine knows are pending, with the time their first part
+	// was received. Parts stored by readDeliveryMsg but not yet passed on are not in it,
+	// so they are never swept or evicted before they have been counted.
+	seen, err := store.Pending()
+	if err != nil {
+		logger.Printf("error listing pending messages: %v\n", err)
+		seen = make(map[string]time.Time)
+	}
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		concatMap := make(map[string][]deliverMsgPart)
+		var sweep <-chan time.Time
+		if timeout > 0 {
+			ticker := time.NewTicker(sweepInterval(timeout))
+			defer ticker.Stop()
+			sweep = ticker.C
+		}
+		// flush passes on the parts stored under key, removing them from the store
+		flush := func(key string) {
+			delete(seen, key)
+			parts, err := store.Parts(key)
+			if err != nil {
+				logger.Printf("error loading parts of %v: %v\n", key, err)
+				return
+			}
+			if len(parts) > 0 {
+				deliverMsgCompleteCh <- assembleParts(parts)
+			}
+			if err := store.Remove(key); err != nil {
+				logger.Printf("error removing parts of %v: %v\n", key, err)
+			}
+		}
 		for {
 			select {
 			case <-closeChan:
 				logger.Printf("readPartialDeliveryMsg terminated\n")
 				return
-			case partial := <-deliverMsgPartCh:
-				mapKey := fmt.Sprintf("%s:%s:%d", partial.sender, partial.receiver, partial.refNum)
-				partMsgList, ok := concatMap[mapKey]
-				if ok {
-					partMsgList = append(partMsgList, partial)
-					concatMap[mapKey] = partMsgList
-					if len(partMsgList) == partial.totalParts {
-						sort.Slice(partMsgList, func(i, j int) bool {
-							return partMsgList[i].currentPart < partMsgList[j].currentPart
-						})
-						var fullMsg string
-						for _, partMsg := range partMsgList {
-							fullMsg += partMsg.message
-						}
-						partial.message = fullMsg
-						deliverMsgCompleteCh <- partial
-						delete(concatMap, mapKey)
+			case now := <-sweep:
+				for key, received := range seen {
+					if now.Sub(received) >= timeout {
+						logger.Printf("reassembly of %v timed out\n", key)
+						flush(key)
 					}
-				} else {
-					concatMap[mapKey] = []deliverMsgPart{partial}
 				}
-
+			case partial := <-deliverMsgPartCh:
+				key := partial.key()
+				parts, err := store.Parts(key)
+				if err != nil {
+					logger.Printf("error loading parts of %v: %v\n", key, err)
+					continue
+				}
+				if len(parts) == partial.totalParts {
+					flush(key)
+					continue
+				}
+				if _, ok := seen[key]; ok || len(parts) == 0 {
+					continue
+				}
+				// a new message, make room for it
+				for maxPending > 0 && len(seen) >= maxPending {
+					oldestKey := oldestPending(seen)
+					logger.Printf("evicting %v from reassembly\n", oldestKey)
+					flush(oldestKey)
+				}
+				seen[key] = firstReceived(parts)
 			}
 		}
 	}()
 }
 
-// readPartialDeliveryMsg processes complete incoming mobile-originating messages
+// key returns the reassembly key of the message the part belongs to.
+func (p deliverMsgPart) key() string {
+	return fmt.Sprintf("%s:%s:%d", p.sender, p.receiver, p.refNum)
+}
+
+func (p deliverMsgPart) messagePart(received time.Time) MessagePart {
+	return MessagePart{
+		Sender:       p.sender,
+		Receiver:     p.receiver,
+		MessageID:    p.msgID,
+		DCS:          p.dcs,
+		Message:      p.message,
+		Ref:          p.refNum,
+		Part:         p.currentPart,
+		Parts:        p.totalParts,
+		Received:     received,
+		LockingShift: p.shift.locking,
+		SingleShift:  p.shift.single,
+	}
+}
+
+// assembleParts concatenates the parts of a message, sorted by part number,
+// and records the numbers of the missing parts.
+func assembleParts(parts []MessagePart) deliverMsgPart {
+	last := parts[len(parts)-1]
+	var fullMsg string
+	for _, part := range parts {
+		fullMsg += part.Message
+	}
+	return deliverMsgPart{
+		currentPart: last.Part,
+		totalParts:  last.Parts,
+		refNum:      last.Ref,
+		sender:      last.Sender,
+		receiver:    last.Receiver,
+		message:     fullMsg,
+		msgID:       last.MessageID,
+		dcs:         last.DCS,
+		shift:       shift{locking: last.LockingShift, single: last.SingleShift},
+		missing:     missingParts(parts),
+	}
+}
+
+// missingParts returns the numbers of the parts of a message not received yet.
+func missingParts(parts []MessagePart) []int {
+	var missing []int
+	next := 0
+	for n := 1; n <= parts[len(parts)-1].Parts; n++ {
+		if next < len(parts) && parts[next].Part == n {
+			next++
+			continue
+		}
+		missing = append(missing, n)
+	}
+	return missing
+}
+
+// oldestPending returns the key of the message pending for the longest time.
+func oldestPending(pending map[string]time.Time) string {
+	var oldestKey string
+	var oldest time.Time
+	for key, received := range pending {
+		if oldestKey == "" || received.Before(oldest) {
+			oldestKey, oldest = key, received
+		}
+	}
+	return oldestKey
+}
+
+// firstReceived returns the time the earliest of parts was received.
+func firstReceived(parts []MessagePart) time.Time {
+	first := parts[0].Received
+	for _, part := range parts[1:] {
+		if part.Received.Before(first) {
+			first = part.Received
+		}
+	}
+	return first
+}
+
+// sweepInterval returns how often pending messages are checked for the reassembly timeout.
+func sweepInterval(timeout time.Duration) time.Duration {
+	if timeout < 4*time.Second {
+		return timeout / 4
+	}
+	return time.Second
+}
+
+// readCompleteDeliveryMsg processes complete incoming mobile-originating messages.
+// Incomplete messages are passed to incompleteHandler if set, else logged and dropped.
 func readCompleteDeliveryMsg(wg *sync.WaitGroup, closeChan chan struct{},
-	deliverMsgCompleteCh chan deliverMsgPart, shortMessageHandler Handler, accessCode string, logger Logger) {
+	deliverMsgCompleteCh chan deliverMsgPart, shortMessageHandler Handler, incompleteHandler IncompleteHandler,
+	accessCode string, logger Logger) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -199,11 +365,27 @@ func readCompleteDeliveryMsg(wg *sync.WaitGroup, closeChan chan struct{},
 				logger.Printf("readCompleteDeliveryMsg terminated\n")
 				return
 			case complete := <-deliverMsgCompleteCh:
+				if len(complete.missing) > 0 {
+					if incompleteHandler == nil {
+						logger.Printf("dropped message %s from %s, missing parts %v\n",
+							complete.msgID, complete.sender, complete.missing)
+						continue
+					}
+					incompleteHandler(
+						complete.sender,
+						complete.receiver,
+						complete.msgID,
+						encodeDeliverMsg(complete.message, complete.dcs, complete.shift),
+						accessCode,
+						complete.missing,
+					)
+					continue
+				}
 				shortMessageHandler(
 					complete.sender,
 					complete.receiver,
 					complete.msgID,
-					encodeDeliverMsg(complete.message, complete.dcs),
+					encodeDeliverMsg(complete.message, complete.dcs, complete.shift),
 					accessCode,
 				)
 			}
@@ -211,11 +393,15 @@ func readCompleteDeliveryMsg(wg *sync.WaitGroup, closeChan chan struct{},
 	}()
 }
 
-// encodeDeliverMsg encodes a mobile-originating message
-func encodeDeliverMsg(mo string, dcs string) string {
+// encodeDeliverMsg encodes a mobile-originating message.
+// GSM 7-bit messages are decoded with the national language tables of sh.
+func encodeDeliverMsg(mo string, dcs string, sh shift) string {
 	var msg string
 	if dcs == dcsXserASCII {
 		msgByte, _ := charset.ParseOddHexStr(mo)
+		if sh != (shift{}) {
+			return sh.decode(msgByte)
+		}
 		msg, _ = charset.Decode7Bit(msgByte)
 	}
 	if dcs == dcsXserUCS2 {
@@ -235,4 +421,8 @@ type deliverMsgPart struct {
 	message     string
 	msgID       string
 	dcs         string
+	// shift is the national language tables of a GSM 7-bit message
+	shift shift
+	// missing are the numbers of the parts missing from an incomplete message
+	missing []int
 }
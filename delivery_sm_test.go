@@ -3,12 +3,15 @@ package ucp
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"log"
 	"os"
 	"reflect"
 	"runtime"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestDeliverSm(t *testing.T) {
@@ -23,8 +26,8 @@ func TestDeliverSm(t *testing.T) {
 		muconn: &sync.Mutex{},
 		logger: log.New(os.Stdout, "debug ", 0),
 	}
-	readDeliveryMsg(writer, wg, closeChan, deliverMsgCh, deliverMsgPartCh, deliverMsgCompleteCh,
-		client.muconn, client)
+	readDeliveryMsg(writer, wg, closeChan, deliverMsgCh, deliverMsgPartCh, deliverMsgCompleteCh, NewMemoryReassemblyStore(),
+		client.muconn, nil, client)
 	runtime.Gosched()
 
 	mobileOriginatingMessage := []string{"26", "00408", "O", "52", "2371", "09191234567", "", "", "", "", "", "", "", "", "", "", "", "0000", "121017010208", "", "", "", "3", "", "41414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141414141", "", "", "0", "", "", "", "", "", "", "020100", "", "", "BF"}
@@ -43,7 +46,7 @@ func TestDeliverSm(t *testing.T) {
 	close(closeChan)
 	wg.Wait()
 	if !reflect.DeepEqual(actual, expected) {
-		t.Errorf("Expected %q, got %q\n", expected, actual)
+		t.Errorf("Expected %+v, got %+v\n", expected, actual)
 	}
 
 	expectedBytesWritten := []byte("\x0226/00037/R/52/A//2371:121017010208/03\x03")
@@ -53,6 +56,25 @@ func TestDeliverSm(t *testing.T) {
 	}
 }
 
+func TestDeliverSmAlphanumericSender(t *testing.T) {
+	wg := new(sync.WaitGroup)
+	closeChan := make(chan struct{})
+	deliverMsgCh := make(chan []string, 1)
+	deliverMsgCompleteCh := make(chan deliverMsgPart, 1)
+	client := &Client{muconn: &sync.Mutex{}}
+	readDeliveryMsg(bufio.NewWriter(new(bytes.Buffer)), wg, closeChan, deliverMsgCh, make(chan deliverMsgPart, 1),
+		deliverMsgCompleteCh, NewMemoryReassemblyStore(), client.muconn, nil, client)
+
+	mo := []string{"26", "00408", "O", "52", "2371", maskSender("Voyager"), "", "", "", "", "", "", "", "", "", "", "", "", "121017010208", "", "", "", "3", "", "41", "", "", "", "", "", "", "", oAdCAlphaNum, "", "020100", "", "", "BF"}
+	deliverMsgCh <- mo
+	actual := <-deliverMsgCompleteCh
+	close(closeChan)
+	wg.Wait()
+	if actual.sender != "Voyager" || actual.msgID != "Voyager:121017010208" {
+		t.Errorf("Expected sender Voyager, got %q %q\n", actual.sender, actual.msgID)
+	}
+}
+
 func TestDeliverSmMultiPartIncomplete(t *testing.T) {
 
 	buf := new(bytes.Buffer)
@@ -67,8 +89,8 @@ func TestDeliverSmMultiPartIncomplete(t *testing.T) {
 		muconn: &sync.Mutex{},
 		logger: log.New(os.Stdout, "debug ", 0),
 	}
-	readDeliveryMsg(writer, wg, closeChan, deliverMsgCh, deliverMsgPartCh, deliverMsgCompleteCh,
-		client.muconn, client)
+	readDeliveryMsg(writer, wg, closeChan, deliverMsgCh, deliverMsgPartCh, deliverMsgCompleteCh, NewMemoryReassemblyStore(),
+		client.muconn, nil, client)
 	runtime.Gosched()
 
 	mobileOriginatingMessage := []string{"05", "00410", "O", "52", "2371", "09191234567", "", "", "", "", "", "", "", "", "", "", "", "0000", "290917182523", "", "", "", "3", "", "44696420796F7520657665722068656172207468652074726167656479206F6620446172746820506C6167756569732054686520576973653F20492074686F75676874206E6F742E2049742773206E6F7420612073746F727920746865204A65646920776F756C642074656C6C20796F752E204974277320612053697468206C6567656E642E20446172746820506C61677565697320776173", "", "", "0", "", "", "", "", "", "", "01060500036D0501020100", "", "", "21"}
@@ -90,7 +112,7 @@ func TestDeliverSmMultiPartIncomplete(t *testing.T) {
 	wg.Wait()
 
 	if !reflect.DeepEqual(actual, expected) {
-		t.Errorf("Expected %q, got %q\n", expected, actual)
+		t.Errorf("Expected %+v, got %+v\n", expected, actual)
 	}
 
 	expectedBytesWritten := []byte("\x0205/00037/R/52/A//2371:290917182523/1A\x03")
@@ -100,6 +122,32 @@ func TestDeliverSmMultiPartIncomplete(t *testing.T) {
 	}
 }
 
+func TestDeliverSmMultiPart16BitRef(t *testing.T) {
+	wg := new(sync.WaitGroup)
+	closeChan := make(chan struct{})
+	deliverMsgCh := make(chan []string)
+	deliverMsgPartCh := make(chan deliverMsgPart, 1)
+	deliverMsgCompleteCh := make(chan deliverMsgPart, 1)
+	client := &Client{muconn: &sync.Mutex{}}
+	readDeliveryMsg(bufio.NewWriter(new(bytes.Buffer)), wg, closeChan, deliverMsgCh, deliverMsgPartCh, deliverMsgCompleteCh,
+		NewMemoryReassemblyStore(), client.muconn, nil, client)
+
+	deliverMsgCh <- []string{"05", "00413", "O", "52", "2371", "09191234567", "", "", "", "", "", "", "", "", "", "", "", "0000", "290917182523", "", "", "", "3", "", "41", "", "", "0", "", "", "", "", "", "", "010706080412AB0302020100", "", "", "21"}
+	actual := <-deliverMsgPartCh
+	close(closeChan)
+	wg.Wait()
+	if actual.refNum != 0x12AB || actual.totalParts != 3 || actual.currentPart != 2 {
+		t.Errorf("Expected part 2 of 3 with reference 0x12AB, got %+v\n", actual)
+	}
+}
+
+// storePart adds a part to the store and passes it on, like readDeliveryMsg.
+func storePart(store ReassemblyStore, deliverMsgPartCh chan deliverMsgPart, part deliverMsgPart) {
+	if added, _ := store.Add(part.key(), part.messagePart(time.Now())); added {
+		deliverMsgPartCh <- part
+	}
+}
+
 func TestDeliverSmMultiPartComplete(t *testing.T) {
 
 	wg := new(sync.WaitGroup)
@@ -107,34 +155,35 @@ func TestDeliverSmMultiPartComplete(t *testing.T) {
 
 	deliverMsgPartCh := make(chan deliverMsgPart, 1)
 	deliverMsgCompleteCh := make(chan deliverMsgPart, 1)
-	readPartialDeliveryMsg(wg, closeChan, deliverMsgPartCh, deliverMsgCompleteCh,
+	store := NewMemoryReassemblyStore()
+	readPartialDeliveryMsg(wg, closeChan, deliverMsgPartCh, deliverMsgCompleteCh, store, 0, 0,
 		&Client{logger: log.New(os.Stdout, "debug ", 0)})
 	runtime.Gosched()
 
-	deliverMsgPartCh <- deliverMsgPart{
+	storePart(store, deliverMsgPartCh, deliverMsgPart{
 		currentPart: 1,
 		totalParts:  3,
 		refNum:      109,
 		sender:      "09191234567",
 		receiver:    "2371",
 		message:     "PART1",
-	}
-	deliverMsgPartCh <- deliverMsgPart{
+	})
+	storePart(store, deliverMsgPartCh, deliverMsgPart{
 		currentPart: 3,
 		totalParts:  3,
 		refNum:      109,
 		sender:      "09191234567",
 		receiver:    "2371",
 		message:     "PART3",
-	}
-	deliverMsgPartCh <- deliverMsgPart{
+	})
+	storePart(store, deliverMsgPartCh, deliverMsgPart{
 		currentPart: 2,
 		totalParts:  3,
 		refNum:      109,
 		sender:      "09191234567",
 		receiver:    "2371",
 		message:     "PART2",
-	}
+	})
 	actual := <-deliverMsgCompleteCh
 	close(closeChan)
 	wg.Wait()
@@ -142,6 +191,158 @@ func TestDeliverSmMultiPartComplete(t *testing.T) {
 	if actual.message != expectedMessage {
 		t.Errorf("Expected %v, got %v\n", expectedMessage, actual.message)
 	}
+	if pending, _ := store.Pending(); len(pending) != 0 {
+		t.Errorf("Expected the parts to be removed from the store, got %v\n", pending)
+	}
+}
+
+func TestDeliverSmMultiPartDuplicate(t *testing.T) {
+	wg := new(sync.WaitGroup)
+	closeChan := make(chan struct{})
+	deliverMsgCh := make(chan []string)
+	deliverMsgPartCh := make(chan deliverMsgPart, 2)
+	deliverMsgCompleteCh := make(chan deliverMsgPart, 1)
+	client := &Client{muconn: &sync.Mutex{}}
+	readDeliveryMsg(bufio.NewWriter(new(bytes.Buffer)), wg, closeChan, deliverMsgCh, deliverMsgPartCh, deliverMsgCompleteCh,
+		NewMemoryReassemblyStore(), client.muconn, nil, client)
+
+	mo := []string{"05", "00410", "O", "52", "2371", "09191234567", "", "", "", "", "", "", "", "", "", "", "", "0000", "290917182523", "", "", "", "3", "", "41", "", "", "0", "", "", "", "", "", "", "01060500036D0501020100", "", "", "21"}
+	deliverMsgCh <- mo
+	deliverMsgCh <- mo
+	close(closeChan)
+	wg.Wait()
+	if n := len(deliverMsgPartCh); n != 1 {
+		t.Errorf("Expected the duplicate part to be ignored, got %d parts\n", n)
+	}
+}
+
+// failingStore is a ReassemblyStore that cannot add parts.
+type failingStore struct {
+	ReassemblyStore
+}
+
+func (failingStore) Add(key string, part MessagePart) (bool, error) {
+	return false, errors.New("disk full")
+}
+
+func TestDeliverSmMultiPartStoreError(t *testing.T) {
+	buf := new(bytes.Buffer)
+	wg := new(sync.WaitGroup)
+	closeChan := make(chan struct{})
+	deliverMsgCh := make(chan []string)
+	deliverMsgPartCh := make(chan deliverMsgPart, 1)
+	deliverMsgCompleteCh := make(chan deliverMsgPart, 1)
+	client := &Client{muconn: &sync.Mutex{}}
+	readDeliveryMsg(bufio.NewWriter(buf), wg, closeChan, deliverMsgCh, deliverMsgPartCh, deliverMsgCompleteCh,
+		failingStore{NewMemoryReassemblyStore()}, client.muconn, nil, client)
+
+	deliverMsgCh <- []string{"05", "00410", "O", "52", "2371", "09191234567", "", "", "", "", "", "", "", "", "", "", "", "0000", "290917182523", "", "", "", "3", "", "41", "", "", "0", "", "", "", "", "", "", "01060500036D0501020100", "", "", "21"}
+	close(closeChan)
+	wg.Wait()
+	if n := len(deliverMsgPartCh); n != 0 {
+		t.Errorf("Expected the part not to be passed on, got %d parts\n", n)
+	}
+	expectedBytesWritten := []byte("\x0205/00037/R/52/N/04/part not stored/EC\x03")
+	if actualBytesWritten := buf.Bytes(); !bytes.Equal(expectedBytesWritten, actualBytesWritten) {
+		t.Errorf("Expected %q, got %q\n", expectedBytesWritten, actualBytesWritten)
+	}
+}
+
+func TestDeliverSmMultiPartExpire(t *testing.T) {
+	testcases := []struct {
+		name       string
+		timeout    time.Duration
+		maxPending int
+		refNums    []int
+	}{
+		{"timeout", 20 * time.Millisecond, 0, []int{1}},
+		{"eviction", 0, 1, []int{1, 2}},
+		{"eviction of the oldest", 0, 2, []int{1, 2, 3}},
+	}
+	for _, tc := range testcases {
+		wg := new(sync.WaitGroup)
+		closeChan := make(chan struct{})
+		deliverMsgPartCh := make(chan deliverMsgPart)
+		deliverMsgCompleteCh := make(chan deliverMsgPart, 1)
+		store := NewMemoryReassemblyStore()
+		readPartialDeliveryMsg(wg, closeChan, deliverMsgPartCh, deliverMsgCompleteCh, store, tc.timeout, tc.maxPending, &Client{})
+		// store every part before passing any on, as readDeliveryMsg may run ahead
+		received := time.Now()
+		parts := make([]deliverMsgPart, 0, len(tc.refNums))
+		for i, refNum := range tc.refNums {
+			part := deliverMsgPart{currentPart: 2, totalParts: 3, refNum: refNum, sender: "09191234567", receiver: "2371", message: "PART2"}
+			store.Add(part.key(), part.messagePart(received.Add(time.Duration(i)*time.Millisecond)))
+			parts = append(parts, part)
+		}
+		for _, part := range parts {
+			deliverMsgPartCh <- part
+		}
+		select {
+		case actual := <-deliverMsgCompleteCh:
+			if actual.refNum != 1 || actual.message != "PART2" || !reflect.DeepEqual(actual.missing, []int{1, 3}) {
+				t.Errorf("%s: expected message 1 missing parts 1 and 3, got %+v\n", tc.name, actual)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%s: expected an incomplete message\n", tc.name)
+		}
+		close(closeChan)
+		wg.Wait()
+	}
+}
+
+func TestReadCompleteDeliverMsgIncomplete(t *testing.T) {
+	wg := new(sync.WaitGroup)
+	closeChan := make(chan struct{})
+	deliverMsgCompleteCh := make(chan deliverMsgPart)
+	missingParts := make(chan []int, 1)
+	f := func(sender, receiver, messageID, message, accessCode string) {
+		t.Errorf("Expected the incomplete handler to be called\n")
+	}
+	incomplete := func(sender, receiver, messageID, message, accessCode string, missing []int) {
+		if message != "😃" {
+			t.Errorf("Expected %v, got %#v\n", "😃", message)
+		}
+		missingParts <- missing
+	}
+	readCompleteDeliveryMsg(wg, closeChan, deliverMsgCompleteCh, f, incomplete, "", &Client{})
+	deliverMsgCompleteCh <- deliverMsgPart{
+		sender:   "09191234567",
+		receiver: "2371",
+		message:  "D83DDE03",
+		dcs:      dcsXserUCS2,
+		missing:  []int{2},
+	}
+	if missing := <-missingParts; !reflect.DeepEqual(missing, []int{2}) {
+		t.Errorf("Expected missing part 2, got %v\n", missing)
+	}
+	close(closeChan)
+	wg.Wait()
+}
+
+func TestReadCompleteDeliverMsgIncompleteDropped(t *testing.T) {
+	wg := new(sync.WaitGroup)
+	closeChan := make(chan struct{})
+	deliverMsgCompleteCh := make(chan deliverMsgPart)
+	f := func(sender, receiver, messageID, message, accessCode string) {
+		t.Errorf("Expected the incomplete message to be dropped, got %#v\n", message)
+	}
+	buf := new(bytes.Buffer)
+	client := &Client{logger: log.New(buf, "", 0)}
+	readCompleteDeliveryMsg(wg, closeChan, deliverMsgCompleteCh, f, nil, "", client)
+	deliverMsgCompleteCh <- deliverMsgPart{
+		sender:   "09191234567",
+		receiver: "2371",
+		msgID:    "2371:190218211530",
+		message:  "D83DDE03",
+		dcs:      dcsXserUCS2,
+		missing:  []int{2},
+	}
+	close(closeChan)
+	wg.Wait()
+	expected := "dropped message 2371:190218211530 from 09191234567, missing parts [2]\n"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("Expected %q, got %q\n", expected, buf.String())
+	}
 }
 
 func TestReadCompleteDeliverMsg(t *testing.T) {
@@ -170,7 +371,7 @@ func TestReadCompleteDeliverMsg(t *testing.T) {
 		muconn: &sync.Mutex{},
 		logger: log.New(os.Stdout, "debug ", 0),
 	}
-	readCompleteDeliveryMsg(wg, closeChan, deliverMsgCompleteCh, f, "", client)
+	readCompleteDeliveryMsg(wg, closeChan, deliverMsgCompleteCh, f, nil, "", client)
 	runtime.Gosched()
 	deliverMsgCompleteCh <- deliverMsgPart{
 		sender:   expectedSender,
@@ -190,11 +391,15 @@ func TestEncodeDeliverMsg(t *testing.T) {
 	}{
 		{
 			expected: "Did you ever hear the tragedy of Darth Plagueis The Wise? I thought not. It's not a story the Jedi would tell you. It's a Sith legend. Darth Plagueis was",
-			actual:   encodeDeliverMsg("44696420796F7520657665722068656172207468652074726167656479206F6620446172746820506C6167756569732054686520576973653F20492074686F75676874206E6F742E2049742773206E6F7420612073746F727920746865204A65646920776F756C642074656C6C20796F752E204974277320612053697468206C6567656E642E20446172746820506C61677565697320776173", dcsXserASCII),
+			actual:   encodeDeliverMsg("44696420796F7520657665722068656172207468652074726167656479206F6620446172746820506C6167756569732054686520576973653F20492074686F75676874206E6F742E2049742773206E6F7420612073746F727920746865204A65646920776F756C642074656C6C20796F752E204974277320612053697468206C6567656E642E20446172746820506C61677565697320776173", dcsXserASCII, shift{}),
 		},
 		{
 			expected: "😃",
-			actual:   encodeDeliverMsg("D83DDE03", dcsXserUCS2),
+			actual:   encodeDeliverMsg("D83DDE03", dcsXserUCS2, shift{}),
+		},
+		{
+			expected: "şş",
+			actual:   encodeDeliverMsg("1B731D", dcsXserASCII, shift{locking: LanguageTurkish, single: LanguageTurkish}),
 		},
 	}
 	for _, testCase := range testCases {
@@ -0,0 +1,306 @@
+package ucp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-gsm/charset"
+)
+
+// operationNames maps an operation code to its EMI name.
+var operationNames = map[string]string{
+	"01":                   "Call Input",
+	"02":                   "Multiple Address Call Input",
+	"03":                   "Call Input with Supplementary Services",
+	"30":                   "SMS Message Transfer",
+	opAlert:                "Alert",
+	opSubmitShortMessage:   "Submit Short Message",
+	opDeliveryShortMessage: "Deliver Short Message",
+	opDeliveryNotification: "Deliver Notification",
+	"54":                   "Modify Message",
+	"55":                   "Inquiry Message",
+	"56":                   "Delete Message",
+	"57":                   "Response Inquiry Message",
+	"58":                   "Response Delete Message",
+	opSessionManagement:    "Session Management",
+	"61":                   "Provisioning Actions",
+}
+
+// messageFieldNames are the positional fields of the 5x operations.
+var messageFieldNames = []string{
+	"AdC", "OAdC", "AC", "NRq", "NAdC", "NT", "NPID", "LRq", "LRAd", "LPID", "DD", "DDT", "VP",
+	"RPID", "SCTS", "Dst", "Rsn", "DSCTS", "MT", "NB", "Msg", "MMS", "PR", "DCs", "MCLs", "RPI",
+	"CPg", "RPLy", "OTOA", "HPLMN", "Xser", "RES4", "RES5",
+}
+
+// operationFieldNames maps an operation code to the positional fields of the operation.
+var operationFieldNames = map[string][]string{
+	"01":                   {"AdC", "OAdC", "AC", "MT", "Msg"},
+	opAlert:                {"AdC", "PID"},
+	opSubmitShortMessage:   messageFieldNames,
+	opDeliveryShortMessage: messageFieldNames,
+	opDeliveryNotification: messageFieldNames,
+	"54":                   messageFieldNames,
+	"55":                   messageFieldNames,
+	"56":                   messageFieldNames,
+	"57":                   messageFieldNames,
+	"58":                   messageFieldNames,
+	opSessionManagement:    {"OAdC", "OTON", "ONPI", "STYP", "PWD", "NPWD", "VERS", "LAdC", "LTON", "LNPI", "OPID", "RES1"},
+}
+
+// Field is a single positional field of a dissected UCP frame.
+type Field struct {
+	// Name is the EMI name of the field, e.g. "OAdC".
+	Name string
+	// Value is the field as it appears on the wire.
+	Value string
+	// Decoded is a human readable form of Value, empty if there is nothing to add.
+	Decoded string
+}
+
+// PDU is a UCP frame dissected by Describe.
+type PDU struct {
+	// TRN is the transaction reference number.
+	TRN string
+	// Len is the length announced in the header.
+	Len string
+	// Type is "O" for operations and "R" for results.
+	Type string
+	// OT is the operation code, e.g. "51".
+	OT string
+	// Operation is the EMI name of OT.
+	Operation string
+	// Fields are the data fields between the header and the checksum.
+	Fields []Field
+	// Checksum is the checksum found in the frame.
+	Checksum string
+	// Problems lists checksum, length and layout mismatches found in the frame.
+	Problems []string
+}
+
+// Field returns the value of the named field and whether it is present.
+func (p *PDU) Field(name string) (string, bool) {
+	for _, field := range p.Fields {
+		if field.Name == name {
+			return field.Value, true
+		}
+	}
+	return "", false
+}
+
+// String pretty-prints the PDU, one non-empty field per line.
+func (p *PDU) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s (TRN %s, LEN %s)\n", p.OT, p.Type, p.Operation, p.TRN, p.Len)
+	for _, field := range p.Fields {
+		if field.Value == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "  %-6s %s\n", field.Name, field.Value)
+		if field.Decoded != "" {
+			fmt.Fprintf(&b, "  %-6s => %s\n", "", field.Decoded)
+		}
+	}
+	fmt.Fprintf(&b, "  %-6s %s\n", "CS", p.Checksum)
+	for _, problem := range p.Problems {
+		fmt.Fprintf(&b, "  !! %s\n", problem)
+	}
+	return b.String()
+}
+
+// Describe dissects a raw UCP frame, with or without its STX and ETX delimiters.
+// It labels every field with its EMI name, decodes addresses, messages and extra
+// services, and reports checksum and length mismatches in PDU.Problems.
+func Describe(frame []byte) (*PDU, error) {
+	// ignore anything preceding the start of the frame
+	if i := bytes.LastIndexByte(frame, stx); i >= 0 {
+		frame = frame[i+1:]
+	}
+	body := bytes.TrimFunc(frame, func(r rune) bool {
+		return r == stx || r == etx || r == '\r' || r == '\n'
+	})
+	splitFields := strings.Split(string(body), delimiter)
+	if len(splitFields) < respMinLen+1 {
+		return nil, errInvalidPacket
+	}
+	last := len(splitFields) - 1
+	p := &PDU{
+		TRN:      splitFields[0],
+		Len:      splitFields[1],
+		Type:     splitFields[2],
+		OT:       splitFields[optypeIndex],
+		Checksum: splitFields[last],
+	}
+	p.Operation = operationNames[p.OT]
+	if p.Operation == "" {
+		p.Operation = "unknown operation"
+	}
+
+	if announced, err := strconv.Atoi(p.Len); err != nil || announced != len(body) {
+		p.Problems = append(p.Problems, fmt.Sprintf("length mismatch: header says %s, frame has %d", p.Len, len(body)))
+	}
+	withoutChecksum := body[:len(body)-len(p.Checksum)]
+	if expected := string(checksum(withoutChecksum)); expected != strings.ToUpper(p.Checksum) {
+		p.Problems = append(p.Problems, fmt.Sprintf("checksum mismatch: frame has %s, expected %s", p.Checksum, expected))
+	}
+
+	values := splitFields[optypeIndex+1 : last]
+	names := describeFieldNames(p.Type, p.OT, values)
+	if names != nil && len(names) != len(values) {
+		p.Problems = append(p.Problems, fmt.Sprintf("field count mismatch: %s %s has %d fields, expected %d",
+			p.OT, p.Type, len(values), len(names)))
+	}
+	for i, value := range values {
+		name := fmt.Sprintf("field%d", i+1)
+		if i < len(names) {
+			name = names[i]
+		}
+		p.Fields = append(p.Fields, Field{Name: name, Value: value})
+	}
+	for i := range p.Fields {
+		p.Fields[i].Decoded = p.decodeField(p.Fields[i])
+	}
+	return p, nil
+}
+
+// describeFieldNames returns the names of the data fields of an operation or result.
+func describeFieldNames(opType, op string, values []string) []string {
+	if opType == operationType {
+		return operationFieldNames[op]
+	}
+	if len(values) > 0 && values[0] == negativeAck {
+		return []string{"NAck", "EC", "SM"}
+	}
+	switch op {
+	case opSubmitShortMessage, opDeliveryShortMessage, opDeliveryNotification, "54", "55", "56", "57", "58":
+		return []string{"ACK", "MVP", "SM"}
+	}
+	return []string{"ACK", "SM"}
+}
+
+// decodeField returns a human readable form of a field, or "" if there is nothing to add.
+func (p *PDU) decodeField(field Field) string {
+	if field.Value == "" {
+		return ""
+	}
+	switch field.Name {
+	case "OAdC":
+		if otoa, _ := p.Field("OTOA"); otoa == oAdCAlphaNum {
+			if sender, err := unmaskSender(field.Value); err == nil {
+				return strconv.Quote(sender)
+			}
+		}
+	case "PWD", "NPWD":
+		if decoded, err := hex.DecodeString(field.Value); err == nil {
+			return strconv.Quote(string(decoded))
+		}
+	case "Msg":
+		return p.decodeMsg(field.Value)
+	case "Xser":
+		return describeXser(field.Value)
+	case "MT":
+		switch field.Value {
+		case numericMessage:
+			return "numeric message"
+		case alphaNumericMessage:
+			return "alphanumeric message"
+		case transparentData:
+			return "transparent data"
+		}
+	case "NT":
+		return describeNotificationType(field.Value)
+	case "Dst":
+		switch field.Value {
+		case "0":
+			return "delivered"
+		case "1":
+			return "buffered"
+		case "2":
+			return "not delivered"
+		}
+	case "SCTS", "DSCTS", "DDT", "VP":
+		if len(field.Value) >= 10 {
+			v := field.Value
+			s := fmt.Sprintf("20%s-%s-%s %s:%s", v[4:6], v[2:4], v[0:2], v[6:8], v[8:10])
+			if len(v) == 12 {
+				s += ":" + v[10:12]
+			}
+			return s
+		}
+	case "EC":
+		if p.Type == resultType {
+			if sm, _ := p.Field("SM"); sm != "" {
+				return "error " + field.Value + ": " + sm
+			}
+		}
+	}
+	return ""
+}
+
+// decodeMsg decodes the Msg field according to the message type and data coding scheme.
+func (p *PDU) decodeMsg(msg string) string {
+	mt, _ := p.Field("MT")
+	if mt == numericMessage {
+		return ""
+	}
+	octets, err := hex.DecodeString(msg)
+	if err != nil {
+		return "invalid hex: " + err.Error()
+	}
+	xser, _ := p.Field("Xser")
+	if x, _ := ParseXSer(xser); x.dataCoding() == dcsXserUCS2 {
+		if text, err := charset.DecodeUcs2(octets); err == nil {
+			return strconv.Quote(text)
+		}
+	}
+	if mt == transparentData {
+		if nb, _ := p.Field("NB"); nb != "" && nb != strconv.Itoa(len(octets)*8) {
+			return fmt.Sprintf("%d octets, NB says %s bits", len(octets), nb)
+		}
+		return fmt.Sprintf("%d octets", len(octets))
+	}
+	if text, err := charset.Decode7Bit(octets); err == nil {
+		return strconv.Quote(text)
+	}
+	return strconv.Quote(string(octets))
+}
+
+// describeXser lists the extra services of an Xser field.
+func describeXser(xser string) string {
+	x, err := ParseXSer(xser)
+	entries := make([]string, 0, len(x)+1)
+	for _, e := range x {
+		data := fmt.Sprintf("%X", e.Data)
+		switch e.Type {
+		case XSerBillingID:
+			data = strconv.Quote(string(e.Data))
+		case XSerGSMUDH:
+			if udh, err := ParseUDH(e.Data); err == nil {
+				data += " (" + udh.String() + ")"
+			}
+		}
+		entries = append(entries, e.Type.String()+"="+data)
+	}
+	if err != nil {
+		entries = append(entries, "invalid entry "+xser[len(x.String()):])
+	}
+	return strings.Join(entries, ", ")
+}
+
+// describeNotificationType lists the notifications requested by an NT bitmask.
+func describeNotificationType(nt string) string {
+	n, err := strconv.Atoi(nt)
+	if err != nil {
+		return ""
+	}
+	kinds := make([]string, 0)
+	for bit, kind := range []string{"delivered", "non-delivered", "buffered"} {
+		if n&(1<<uint(bit)) != 0 {
+			kinds = append(kinds, kind)
+		}
+	}
+	return strings.Join(kinds, "+")
+}
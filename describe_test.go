@@ -0,0 +1,106 @@
+package ucp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	pdu, err := Describe([]byte("\x0201/00126/O/51/09495696599/0ED6773E7C2ECB1B//1//1/////////////3/88/48656C6C6F20776F726C64////1////5039//020100060101070101///47\x03"))
+	if err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	if pdu.Operation != "Submit Short Message" {
+		t.Errorf("Expected %v, got %v\n", "Submit Short Message", pdu.Operation)
+	}
+	if len(pdu.Problems) != 0 {
+		t.Errorf("Expected no problems, got %v\n", pdu.Problems)
+	}
+	fieldTestCases := []struct {
+		name            string
+		expectedValue   string
+		expectedDecoded string
+	}{
+		{"AdC", "09495696599", ""},
+		{"OAdC", "0ED6773E7C2ECB1B", `"Voyager"`},
+		{"NT", "1", "delivered"},
+		{"MT", "3", "alphanumeric message"},
+		{"Msg", "48656C6C6F20776F726C64", `"Hello world"`},
+		{"OTOA", "5039", ""},
+		{"Xser", "020100060101070101", "GSM DCS=00, urgency indicator=01, acknowledgement request=01"},
+	}
+	for _, testCase := range fieldTestCases {
+		found := false
+		for _, field := range pdu.Fields {
+			if field.Name != testCase.name {
+				continue
+			}
+			found = true
+			if field.Value != testCase.expectedValue {
+				t.Errorf("field %s: Expected %v, got %v\n", testCase.name, testCase.expectedValue, field.Value)
+			}
+			if field.Decoded != testCase.expectedDecoded {
+				t.Errorf("field %s: Expected %v, got %v\n", testCase.name, testCase.expectedDecoded, field.Decoded)
+			}
+		}
+		if !found {
+			t.Errorf("field %s: not found\n", testCase.name)
+		}
+	}
+}
+
+func TestDescribeProblems(t *testing.T) {
+	describeTestCases := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			"valid result",
+			"\x0200/00037/R/60/A/BIND AUTHENTICATED/6D\x03",
+			nil,
+		},
+		{
+			"checksum mismatch",
+			"\x0200/00037/R/60/A/BIND AUTHENTICATED/6E\x03",
+			[]string{"checksum mismatch"},
+		},
+		{
+			"length mismatch",
+			"\x0200/00038/R/60/A/BIND AUTHENTICATED/6E\x03",
+			[]string{"length mismatch"},
+		},
+	}
+	for _, testCase := range describeTestCases {
+		pdu, err := Describe([]byte(testCase.input))
+		if err != nil {
+			t.Fatalf("testcase %s: Expected nil error, got %v\n", testCase.name, err)
+		}
+		if len(pdu.Problems) != len(testCase.expected) {
+			t.Fatalf("testcase %s: Expected %v, got %v\n", testCase.name, testCase.expected, pdu.Problems)
+		}
+		for i, problem := range pdu.Problems {
+			if !strings.HasPrefix(problem, testCase.expected[i]) {
+				t.Errorf("testcase %s: Expected %v, got %v\n", testCase.name, testCase.expected[i], problem)
+			}
+		}
+	}
+}
+
+func TestDescribeInvalid(t *testing.T) {
+	if _, err := Describe([]byte("\x02garbage\x03")); err != errInvalidPacket {
+		t.Errorf("Expected %v, got %v\n", errInvalidPacket, err)
+	}
+}
+
+func TestUnmaskSender(t *testing.T) {
+	for _, sender := range []string{"test", "Voyager", "Go-GSM 2018"} {
+		actual, err := unmaskSender(maskSender(sender))
+		if err != nil {
+			t.Errorf("Expected nil error, got %v\n", err)
+		}
+		if actual != sender {
+			t.Errorf("Expected %v, got %v\n", sender, actual)
+		}
+	}
+}
@@ -2,10 +2,11 @@ package ucp
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
 	"reflect"
 	"strconv"
-	"unicode/utf8"
+	"unicode/utf16"
 
 	"github.com/go-gsm/charset"
 )
@@ -36,72 +37,103 @@ func getMessageType(message string) string {
 
 // getMessageParts splits the message into a list
 // such that the length of each element of the string slice
-// is less than the allowed maximum length
-func getMessageParts(message string) []string {
+// is less than the allowed maximum length.
+// The 16-bit concatenation reference takes one more UDH octet than the 8-bit one.
+func getMessageParts(message string, refBits int) []string {
 	if charset.IsGsmAlpha(message) {
-		return asciiParts(message)
+		if refBits == concatRef16 {
+			return asciiParts(message, gsmMaxMultiPart16)
+		}
+		return asciiParts(message, gsmMaxMultiPart)
+	}
+	if refBits == concatRef16 {
+		return ucs2Parts(message, ucs2MaxMultiPart16)
 	}
-	return ucs2Parts(message)
+	return ucs2Parts(message, ucs2MaxMultiPart)
 }
 
-// asciiParts splits ASCII messages
-func asciiParts(message string) []string {
-	// less than max, no need to split
-	if len(charset.Encode7Bit(message)) <= gsmMaxSinglePart {
-		return []string{message}
-	}
-	// greater than max, split it.
+// asciiParts splits ASCII messages in parts of at most maxMultiPart septets
+func asciiParts(message string, maxMultiPart int) []string {
 	// gsmMaxMultiPart is 153 bcoz we need to use the length of the UDH field (in octets),
 	// multiplied by 8/7, rounded up to the nearest integer value.
 	//
 	// length of the UDH field (in octets) => 6
 	// 6 * (8/7) => 7 (rounded up)
 	// 160 - 7 = 153
+	return septetParts(message, gsmMaxSinglePart, maxMultiPart, gsmSeptets)
+}
+
+// septetParts splits a message in parts of at most maxMultiPart septets,
+// if it does not fit in maxSinglePart. septets returns the septets of a character.
+func septetParts(message string, maxSinglePart, maxMultiPart int, septets func(rune) int) []string {
+	total := 0
+	for _, r := range message {
+		total += septets(r)
+	}
+	// less than max, no need to split
+	if total <= maxSinglePart {
+		return []string{message}
+	}
+	// characters of the extension table take two septets (ESC and the character),
+	// a part ends before a character that does not fit, so an escape sequence is never split.
 	parts := make([]string, 0)
-	for start, end := 0, gsmMaxMultiPart; len(message) > start; start, end = start+gsmMaxMultiPart, end+gsmMaxMultiPart {
-		if len(message) < end {
-			end = len(message)
+	start, count := 0, 0
+	for i, r := range message {
+		n := septets(r)
+		if count+n > maxMultiPart {
+			parts = append(parts, message[start:i])
+			start, count = i, 0
 		}
-		part := message[start:end]
-		parts = append(parts, part)
-
+		count += n
 	}
-	return parts
+	return append(parts, message[start:])
+}
+
+// gsmSeptets returns the number of septets of a character of the GSM 7-bit alphabet.
+func gsmSeptets(r rune) int {
+	return len(charset.Encode7Bit(string(r)))
 }
 
-// ucs2Parts splits Unicode messages
-func ucs2Parts(message string) []string {
+// ucs2Parts splits Unicode messages in parts of at most maxMultiPart UTF-16 code units
+func ucs2Parts(message string, maxMultiPart int) []string {
 	// less than max, no need to split
-	if len(message) <= ucs2MaxSinglePart {
+	if ucs2Units(message) <= ucs2MaxSinglePart {
 		return []string{message}
 	}
 	// greater than max, split it.
-	// ucs2MaxMultiPart is 64 bcoz we need to use the length of the UDH field (in octets).
-	//  The length of the UDH field (in octets) plus the length of the TMsg field (in octets) must not exceed 140.
-	// 140 octets is equal to 70 hextets(UCS-2)
-	// 70 - 6 = 64
+	// ucs2MaxMultiPart is 67 bcoz we need to use the length of the UDH field (in octets).
+	// The length of the UDH field (in octets) plus the length of the TMsg field (in octets) must not exceed 140.
+	// 140 - 6 = 134 octets, which is equal to 67 UTF-16 code units.
+	//
+	// characters outside the BMP take two code units (a surrogate pair),
+	// a part ends before a character that does not fit, so a surrogate pair is never split.
 	parts := make([]string, 0)
-	for start, end := 0, ucs2MaxMultiPart; len(message) > start; start, end = end, end+ucs2MaxMultiPart {
-		if len(message) < end {
-			end = len(message)
+	start, units := 0, 0
+	for i, r := range message {
+		n := 1
+		if r > 0xFFFF {
+			n = 2
 		}
-		part := message[start:end]
-		for !utf8.ValidString(part) {
-			// part is not valid, backoff by one
-			end -= 1
-			// re-slice the message
-			part = message[start:end]
+		if units+n > maxMultiPart {
+			parts = append(parts, message[start:i])
+			start, units = i, 0
 		}
-		parts = append(parts, part)
+		units += n
 	}
-	return parts
+	return append(parts, message[start:])
+}
+
+// ucs2Units returns the number of UTF-16 code units of a message.
+func ucs2Units(message string) int {
+	return len(utf16.Encode([]rune(message)))
 }
 
-func getDataCodingScheme(msgType string) string {
+// getDataCodingScheme returns the data coding scheme of the GSM DCS extra service.
+func getDataCodingScheme(msgType string) byte {
 	if msgType == alphaNumericMessage {
-		return dataCodingSchemeASCII
+		return dcsGSM7
 	}
-	return dataCodingSchemeUCS2
+	return dcsUCS2
 }
 
 // buildHexMsg formats the message to a hex string
@@ -109,7 +141,7 @@ func buildHexMsg(messageType string, message string) string {
 	if messageType == alphaNumericMessage {
 		return fmt.Sprintf("%02X", string(charset.Encode7Bit(message)))
 	}
-	return fmt.Sprintf("%04X", string(charset.EncodeUcs2(message)))
+	return fmt.Sprintf("%X", charset.EncodeUcs2(message))
 }
 
 // maskSender formats the sender mask to a hex string
@@ -123,26 +155,97 @@ func maskSender(sender string) string {
 	return encodedHexSender
 }
 
+// unmaskSender decodes a sender mask built by maskSender
+func unmaskSender(hexSender string) (string, error) {
+	octets, err := hex.DecodeString(hexSender)
+	if err != nil {
+		return "", err
+	}
+	if len(octets) == 0 {
+		return "", errInvalidPacket
+	}
+	// the first octet is the number of useful semi-octets of the packed address
+	septets := charset.Unpack7Bit(octets[1:])
+	if n := int(octets[0]) * 4 / 7; n < len(septets) {
+		septets = septets[:n]
+	}
+	return charset.Decode7Bit(septets)
+}
+
 // encodeMessage builds a submit sm packet
-func encodeMessage(transRefNum []byte, sender, receiver, message, messageType, billingID string,
-	referenceNum, msgPartNum, totalMsgParts int) []byte {
+func encodeMessage(transRefNum []byte, sender originator, receiver, message, messageType, billingID string,
+	referenceNum, msgPartNum, totalMsgParts, refBits int, sh shift, params submitParams) []byte {
 
-	encodedHexSender := maskSender(sender)
 	encodedHexMessage := buildHexMsg(messageType, message)
+	if sh != (shift{}) {
+		septets, _ := sh.encode(message)
+		encodedHexMessage = fmt.Sprintf("%X", septets)
+	}
 	numBits := strconv.Itoa(len(encodedHexMessage) * 4)
-	xserData := buildXser(billingID, messageType, referenceNum, totalMsgParts, msgPartNum)
+	xserData := append(buildXser(billingID, getDataCodingScheme(messageType),
+		textUDH(referenceNum, totalMsgParts, msgPartNum, refBits, sh.udh()...), params.urgency, params.ackRequest),
+		sender.xser()...)
+	nrq, nt := params.notifications.fields()
 
 	s := submit{
 		AdC:  []byte(receiver),
-		OAdC: []byte(encodedHexSender),
-		NRq:  []byte(nAdCUsed),
-		NT:   []byte(notificationTypeDN),
+		OAdC: []byte(sender.address),
+		NRq:  []byte(nrq),
+		NT:   []byte(nt),
+		DD:   []byte(params.dd),
+		DDT:  []byte(params.ddt),
+		VP:   []byte(params.vp),
 		MT:   []byte(messageType),
 		NB:   []byte(numBits),
 		Msg:  []byte(encodedHexMessage),
 		MCLs: []byte(messageClass),
-		OTOA: []byte(oAdCAlphaNum),
-		Xser: []byte(xserData),
+		OTOA: []byte(sender.toa),
+		Xser: []byte(xserData.String()),
+	}
+
+	buf := preparePacket(transRefNum, s)
+	return buf
+}
+
+// getBinaryParts splits an 8-bit payload into parts that fit in a short message
+// together with the user data header, and the concatenation element if there is more than one part.
+// It returns ErrUDHTooLong if the headers leave no room for the payload.
+func getBinaryParts(payload []byte, udh UDH, refBits int) ([][]byte, error) {
+	if len(payload) <= octetMaxSinglePart-udh.Len() {
+		return [][]byte{payload}, nil
+	}
+	concatUDH := append(append(UDH(nil), udh...), Concatenation(0, 0, 0, refBits))
+	maxMultiPart := octetMaxSinglePart - concatUDH.Len()
+	if maxMultiPart <= 0 {
+		return nil, ErrUDHTooLong
+	}
+	parts := make([][]byte, 0)
+	for start := 0; start < len(payload); start += maxMultiPart {
+		end := start + maxMultiPart
+		if end > len(payload) {
+			end = len(payload)
+		}
+		parts = append(parts, payload[start:end])
+	}
+	return parts, nil
+}
+
+// encodeBinary builds a submit sm packet of transparent data with a user data header
+func encodeBinary(transRefNum []byte, sender originator, receiver string, payload []byte, udh UDH, dcs byte, billingID string) []byte {
+	encodedHexMessage := fmt.Sprintf("%X", payload)
+	numBits := strconv.Itoa(len(payload) * 8)
+	xserData := append(buildXser(billingID, dcs, udh, UrgencyNormal, AckDelivery), sender.xser()...)
+
+	s := submit{
+		AdC:  []byte(receiver),
+		OAdC: []byte(sender.address),
+		NRq:  []byte(nAdCUsed),
+		NT:   []byte(notificationTypeDN),
+		MT:   []byte(transparentData),
+		NB:   []byte(numBits),
+		Msg:  []byte(encodedHexMessage),
+		OTOA: []byte(sender.toa),
+		Xser: []byte(xserData.String()),
 	}
 
 	buf := preparePacket(transRefNum, s)
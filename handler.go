@@ -4,6 +4,10 @@ import "fmt"
 
 type Handler func(sender, receiver, messageID, message, accessCode string)
 
+// IncompleteHandler is called with the parts received of a multipart message
+// that did not complete within the reassembly timeout, and the numbers of its missing parts.
+type IncompleteHandler func(sender, receiver, messageID, message, accessCode string, missing []int)
+
 // DefaultHandler is called if DeliveryHandler or ShortMessageHandler is not set.
 // It just logs the parameters.
 func DefaultHandler(sender, receiver, messageID, message, accessCode string) {
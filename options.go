@@ -20,10 +20,68 @@ type Options struct {
 	Timeout time.Duration
 	// KeepAlive is the ping interval for sending keep-alive packets to the SMSC
 	KeepAlive time.Duration
+	// ConcatRefBits is the width of the concatenation reference of multipart messages,
+	// 8 (the default) or 16.
+	ConcatRefBits int
+	// RefAllocator hands out the concatenation references of multipart messages.
+	// Clients sending to the same destinations should share one. It overrides ConcatRefBits.
+	RefAllocator *RefAllocator
+	// Location is the timezone of the SMSC, the validity period and delivery time of messages
+	// are sent in its local time. The default is the local timezone.
+	Location *time.Location
+	// Languages are the national languages whose shift tables may encode text messages
+	// in GSM 7-bit rather than UCS-2. The cheapest encoding is chosen for every message,
+	// the receiving handsets must support the languages. Tables are known for Turkish,
+	// Spanish, Portuguese, Urdu and the Indic languages, other languages are ignored.
+	Languages []Language
 	// DeliveryHandler sets the delivery notification handler(delivery receipts).
 	DeliveryHandler Handler
 	// ShortMessageHandler sets the delivery short message handler(mobile originating messages).
 	ShortMessageHandler Handler
+	// IncompleteMessageHandler sets the handler of multipart mobile originating messages missing parts.
+	// If not set, they are logged and dropped when the ReassemblyTimeout expires.
+	IncompleteMessageHandler IncompleteHandler
+	// ReassemblyTimeout is how long the parts of a multipart mobile originating message are kept
+	// waiting for the missing parts, default is 5 minutes.
+	ReassemblyTimeout time.Duration
+	// MaxPendingMessages is the maximum number of multipart mobile originating messages waiting for parts,
+	// default is 1000. The oldest one is given up when a new one arrives.
+	MaxPendingMessages int
+	// ReassemblyStore keeps the parts of multipart mobile originating messages until all of them arrive.
+	// The default keeps them in memory, use a FileReassemblyStore to keep them across restarts.
+	ReassemblyStore ReassemblyStore
+	// DeliveryReportHandler sets the handler of parsed delivery notifications.
+	DeliveryReportHandler ReportHandler
+	// OnFrame is called for every raw frame written to or read from the SMSC.
+	OnFrame FrameHook
+	// CorrelationStore keeps the correlation IDs of sent messages until their delivery reports arrive.
+	// The default keeps them in memory, use a FileCorrelationStore to keep them across restarts.
+	CorrelationStore CorrelationStore
+}
+
+// SendOptions is used to configure the sending of a message.
+type SendOptions struct {
+	// CorrelationID is an ID chosen by the caller, returned with the result of the send
+	// and with the delivery reports of the message. It is not saved when Notifications is NotifyNone,
+	// as no delivery report will come.
+	CorrelationID string
+	// Transliterate replaces typographic punctuation and accented letters with GSM 7-bit
+	// equivalents when that spares a message from being sent in UCS-2.
+	// The replacements made are reported in the SendResult.
+	Transliterate bool
+	// ValidityPeriod is how long the SMSC keeps trying to deliver the message, from its delivery time.
+	// It must be between a minute and 63 weeks, zero leaves it to the SMSC.
+	ValidityPeriod time.Duration
+	// DeliverAt schedules the delivery of the message, within 63 weeks. Zero delivers it now.
+	DeliverAt time.Time
+	// Urgency is the urgency indicator, normal by default.
+	Urgency Urgency
+	// AckRequest is the acknowledgement asked from the handset, a delivery acknowledgement by default.
+	AckRequest AckRequest
+	// Notifications are the notification types requested from the SMSC, delivery notifications by default.
+	Notifications Notification
+	// Originator is the type of address of the sender, detected from the sender if not set.
+	Originator OriginatorType
 }
 
 func setDefaults(opt *Options) *Options {
@@ -33,14 +91,33 @@ func setDefaults(opt *Options) *Options {
 	if opt.KeepAlive == 0 {
 		opt.KeepAlive = 30 * time.Second
 	}
+	if opt.Location == nil {
+		opt.Location = time.Local
+	}
 	if opt.Timeout == 0 {
 		opt.Timeout = 5 * time.Second
 	}
+	if opt.RefAllocator == nil {
+		opt.RefAllocator = NewRefAllocator(opt.ConcatRefBits)
+	}
+	opt.ConcatRefBits = opt.RefAllocator.Bits()
+	if opt.ReassemblyTimeout == 0 {
+		opt.ReassemblyTimeout = 5 * time.Minute
+	}
+	if opt.MaxPendingMessages == 0 {
+		opt.MaxPendingMessages = 1000
+	}
 	if opt.DeliveryHandler == nil {
 		opt.DeliveryHandler = DefaultHandler
 	}
 	if opt.ShortMessageHandler == nil {
 		opt.ShortMessageHandler = DefaultHandler
 	}
+	if opt.ReassemblyStore == nil {
+		opt.ReassemblyStore = NewMemoryReassemblyStore()
+	}
+	if opt.CorrelationStore == nil {
+		opt.CorrelationStore = NewMemoryCorrelationStore()
+	}
 	return opt
 }
@@ -0,0 +1,168 @@
+package ucp
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/go-gsm/charset"
+)
+
+// OriginatorType is the type of address of the sender of a mobile-terminating message.
+type OriginatorType int
+
+const (
+	// OriginatorAuto detects the type from the sender: a number with a leading + is international,
+	// a number of at most 8 digits a short code, other numbers with a leading 0 national,
+	// other numbers international, and anything else alphanumeric.
+	OriginatorAuto OriginatorType = iota
+	// OriginatorAlphanumeric is a text sender of at most 11 GSM 7-bit characters.
+	OriginatorAlphanumeric
+	// OriginatorInternational is a number in international format, with or without a leading +.
+	OriginatorInternational
+	// OriginatorNational is a number in national format.
+	OriginatorNational
+	// OriginatorShortCode is a short code.
+	OriginatorShortCode
+)
+
+func (t OriginatorType) String() string {
+	switch t {
+	case OriginatorAuto:
+		return "auto"
+	case OriginatorAlphanumeric:
+		return "alphanumeric"
+	case OriginatorInternational:
+		return "international"
+	case OriginatorNational:
+		return "national"
+	case OriginatorShortCode:
+		return "short code"
+	}
+	return "OriginatorType(" + strconv.Itoa(int(t)) + ")"
+}
+
+const (
+	// maxAlphanumericSender is the maximum number of characters of an alphanumeric sender
+	maxAlphanumericSender = 11
+	// maxNumericSender is the maximum number of digits of a numeric sender
+	maxNumericSender = 16
+	// maxShortCode is the maximum number of digits of a detected short code
+	maxShortCode = 8
+)
+
+// ErrInvalidOriginator is returned when a sender does not fit its originator type.
+var ErrInvalidOriginator = errors.New("invalid originator")
+
+// Type of number and numbering plan of the originator extra services (3GPP TS 23.040, 9.1.2.5).
+const (
+	tonNational    byte = 0x02
+	tonAbbreviated byte = 0x06
+	npiUnknown     byte = 0x00
+	npiISDN        byte = 0x01
+)
+
+// originator is the encoded sender of a submit packet.
+type originator struct {
+	// address is the OAdC field
+	address string
+	// toa is the OTOA field, empty for national numbers and short codes
+	toa string
+	// typ is the type of the sender
+	typ OriginatorType
+}
+
+// xser returns the originator TON and NPI extra services of national numbers and short codes,
+// whose type the OTOA field does not carry.
+func (o originator) xser() XSer {
+	switch o.typ {
+	case OriginatorNational:
+		return XSer{{XSerOriginatorTON, []byte{tonNational}}, {XSerOriginatorNPI, []byte{npiISDN}}}
+	case OriginatorShortCode:
+		return XSer{{XSerOriginatorTON, []byte{tonAbbreviated}}, {XSerOriginatorNPI, []byte{npiUnknown}}}
+	}
+	return nil
+}
+
+// newOriginator encodes a sender of the given type.
+// Alphanumeric senders are packed in GSM 7-bit with OTOA 5039, international numbers
+// are sent with OTOA 1139 and other numbers with an empty OTOA, as plain digits,
+// with their type of number in the originator extra services.
+func newOriginator(sender string, t OriginatorType) (originator, error) {
+	if t == OriginatorAuto {
+		t = detectOriginatorType(sender)
+	}
+	switch t {
+	case OriginatorAlphanumeric:
+		if sender == "" || !charset.IsGsmAlpha(sender) || len(charset.Encode7Bit(sender)) > maxAlphanumericSender {
+			return originator{}, ErrInvalidOriginator
+		}
+		return originator{maskSender(sender), oAdCAlphaNum, t}, nil
+	case OriginatorInternational:
+		number := strings.TrimPrefix(sender, "+")
+		if !validNumber(number) {
+			return originator{}, ErrInvalidOriginator
+		}
+		return originator{number, oAdCInternational, t}, nil
+	case OriginatorNational, OriginatorShortCode:
+		if !validNumber(sender) {
+			return originator{}, ErrInvalidOriginator
+		}
+		return originator{sender, "", t}, nil
+	}
+	return originator{}, ErrInvalidOriginator
+}
+
+// decodeOriginator returns the readable form of an incoming OAdC field,
+// unpacking it if OTOA says it is alphanumeric. The field is returned as is if it cannot be unpacked.
+func decodeOriginator(oadc, otoa string) string {
+	if otoa != oAdCAlphaNum {
+		return oadc
+	}
+	sender, err := unmaskSender(oadc)
+	if err != nil {
+		return oadc
+	}
+	return sender
+}
+
+// otoaField returns the OTOA field of the fields of an incoming operation, if any.
+func otoaField(fields []string) string {
+	if len(fields) <= otoaIndex {
+		return ""
+	}
+	return fields[otoaIndex]
+}
+
+// detectOriginatorType returns the type of a sender as described for OriginatorAuto.
+func detectOriginatorType(sender string) OriginatorType {
+	switch {
+	case strings.HasPrefix(sender, "+") && isDigits(sender[1:]):
+		return OriginatorInternational
+	case !isDigits(sender):
+		return OriginatorAlphanumeric
+	case len(sender) <= maxShortCode:
+		return OriginatorShortCode
+	case strings.HasPrefix(sender, "0"):
+		return OriginatorNational
+	}
+	return OriginatorInternational
+}
+
+// validNumber reports whether number has 1 to maxNumericSender digits.
+func validNumber(number string) bool {
+	return isDigits(number) && len(number) <= maxNumericSender
+}
+
+// isDigits reports whether s is a non-empty string of decimal digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
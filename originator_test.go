@@ -0,0 +1,78 @@
+package ucp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewOriginator(t *testing.T) {
+	originatorTestCases := []struct {
+		name     string
+		sender   string
+		typ      OriginatorType
+		expected originator
+		err      error
+	}{
+		{"alphanumeric", "Voyager", OriginatorAuto, originator{maskSender("Voyager"), oAdCAlphaNum, OriginatorAlphanumeric}, nil},
+		{"international with plus", "+639171234567", OriginatorAuto, originator{"639171234567", oAdCInternational, OriginatorInternational}, nil},
+		{"international without plus", "639171234567", OriginatorAuto, originator{"639171234567", oAdCInternational, OriginatorInternational}, nil},
+		{"national", "09171234567", OriginatorAuto, originator{"09171234567", "", OriginatorNational}, nil},
+		{"short code", "2371", OriginatorAuto, originator{"2371", "", OriginatorShortCode}, nil},
+		{"digits sent as alphanumeric", "2371", OriginatorAlphanumeric, originator{maskSender("2371"), oAdCAlphaNum, OriginatorAlphanumeric}, nil},
+		{"short code given as national", "2371", OriginatorNational, originator{"2371", "", OriginatorNational}, nil},
+		{"11 characters", "ABCDEFGHIJK", OriginatorAuto, originator{maskSender("ABCDEFGHIJK"), oAdCAlphaNum, OriginatorAlphanumeric}, nil},
+		{"12 characters", "ABCDEFGHIJKL", OriginatorAuto, originator{}, ErrInvalidOriginator},
+		{"extension characters take two septets", "ABCDEFGHIJ€", OriginatorAuto, originator{}, ErrInvalidOriginator},
+		{"not gsm", "Привет", OriginatorAuto, originator{}, ErrInvalidOriginator},
+		{"empty", "", OriginatorAuto, originator{}, ErrInvalidOriginator},
+		{"16 digits", "+1234567890123456", OriginatorAuto, originator{"1234567890123456", oAdCInternational, OriginatorInternational}, nil},
+		{"17 digits", "+12345678901234567", OriginatorAuto, originator{}, ErrInvalidOriginator},
+		{"letters in a number", "0917ABC", OriginatorNational, originator{}, ErrInvalidOriginator},
+		{"unknown type", "2371", OriginatorType(9), originator{}, ErrInvalidOriginator},
+	}
+
+	for _, testCase := range originatorTestCases {
+		actual, err := newOriginator(testCase.sender, testCase.typ)
+		if actual != testCase.expected || err != testCase.err {
+			t.Errorf("testcase %s: Expected %+v %v, got %+v %v\n", testCase.name, testCase.expected, testCase.err, actual, err)
+		}
+	}
+}
+
+func TestOriginatorXser(t *testing.T) {
+	originatorXserTestCases := []struct {
+		name   string
+		sender string
+		ton    []byte
+		npi    []byte
+	}{
+		{"alphanumeric", "Voyager", nil, nil},
+		{"international", "+639171234567", nil, nil},
+		{"national", "09171234567", []byte{0x02}, []byte{0x01}},
+		{"short code", "2371", []byte{0x06}, []byte{0x00}},
+	}
+
+	for _, testCase := range originatorXserTestCases {
+		oadc, err := newOriginator(testCase.sender, OriginatorAuto)
+		if err != nil {
+			t.Fatalf("testcase %s: Unexpected error %v\n", testCase.name, err)
+		}
+		frames := map[string][]byte{
+			"text":   encodeMessage([]byte("01"), oadc, "09495696599", "hi", alphaNumericMessage, "", 0, 1, 1, concatRef8, shift{}, submitParams{}),
+			"binary": encodeBinary([]byte("01"), oadc, "09495696599", []byte{0xCA, 0xFE}, nil, 0x04, ""),
+		}
+		for kind, frame := range frames {
+			pdu, err := Describe(frame)
+			if err != nil || len(pdu.Problems) > 0 {
+				t.Fatalf("testcase %s %s: invalid frame %q: %v %v\n", testCase.name, kind, frame, err, pdu.Problems)
+			}
+			field, _ := pdu.Field("Xser")
+			xser, _ := ParseXSer(field)
+			ton, _ := xser.Find(XSerOriginatorTON)
+			npi, _ := xser.Find(XSerOriginatorNPI)
+			if !bytes.Equal(ton.Data, testCase.ton) || !bytes.Equal(npi.Data, testCase.npi) {
+				t.Errorf("testcase %s %s: Expected TON %X NPI %X, got %X %X\n", testCase.name, kind, testCase.ton, testCase.npi, ton.Data, npi.Data)
+			}
+		}
+	}
+}
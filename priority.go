@@ -0,0 +1,130 @@
+package ucp
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Urgency is the urgency indicator extra service of a message.
+type Urgency int
+
+const (
+	// UrgencyNormal is the default urgency.
+	UrgencyNormal Urgency = iota
+	// UrgencyBulk is for messages that can wait, e.g. marketing traffic.
+	UrgencyBulk
+	// UrgencyUrgent is for messages that must not wait, e.g. one-time passwords.
+	UrgencyUrgent
+	// UrgencyVeryUrgent is delivered before urgent messages.
+	UrgencyVeryUrgent
+)
+
+// urgencyXser maps an urgency to the data of its urgency indicator extra service.
+var urgencyXser = map[Urgency]byte{
+	UrgencyBulk:       0x00,
+	UrgencyNormal:     0x01,
+	UrgencyUrgent:     0x02,
+	UrgencyVeryUrgent: 0x03,
+}
+
+func (u Urgency) String() string {
+	switch u {
+	case UrgencyNormal:
+		return "normal"
+	case UrgencyBulk:
+		return "bulk"
+	case UrgencyUrgent:
+		return "urgent"
+	case UrgencyVeryUrgent:
+		return "very urgent"
+	}
+	return "Urgency(" + strconv.Itoa(int(u)) + ")"
+}
+
+// AckRequest is the acknowledgement request extra service of a message,
+// the acknowledgement asked from the handset.
+type AckRequest int
+
+const (
+	// AckDelivery requests a delivery acknowledgement, the default.
+	AckDelivery AckRequest = iota
+	// AckNone requests no acknowledgement.
+	AckNone
+	// AckManual requests a manual (user) acknowledgement.
+	AckManual
+	// AckDeliveryAndManual requests both acknowledgements.
+	AckDeliveryAndManual
+)
+
+// ackRequestXser maps an acknowledgement request to the data of its extra service.
+var ackRequestXser = map[AckRequest]byte{
+	AckNone:              0x00,
+	AckDelivery:          0x01,
+	AckManual:            0x02,
+	AckDeliveryAndManual: 0x03,
+}
+
+func (a AckRequest) String() string {
+	switch a {
+	case AckDelivery:
+		return "delivery"
+	case AckNone:
+		return "none"
+	case AckManual:
+		return "manual"
+	case AckDeliveryAndManual:
+		return "delivery and manual"
+	}
+	return "AckRequest(" + strconv.Itoa(int(a)) + ")"
+}
+
+// Notification is the bitmask of the notification types (NT) requested for a message.
+// Zero requests delivery notifications only.
+type Notification int
+
+const (
+	// NotifyDelivered requests a notification when the message is delivered.
+	NotifyDelivered Notification = 1 << iota
+	// NotifyNonDelivered requests a notification when the message cannot be delivered.
+	NotifyNonDelivered
+	// NotifyBuffered requests a notification when the message is buffered by the SMSC.
+	NotifyBuffered
+	// NotifyAll requests every notification type.
+	NotifyAll = NotifyDelivered | NotifyNonDelivered | NotifyBuffered
+	// NotifyNone requests no notification.
+	NotifyNone Notification = -1
+)
+
+// fields returns the NRq and NT fields of the notification types.
+func (n Notification) fields() (nrq, nt string) {
+	switch n {
+	case NotifyNone:
+		return notificationNotRequested, ""
+	case 0:
+		return nAdCUsed, notificationTypeDN
+	}
+	return nAdCUsed, strconv.Itoa(int(n))
+}
+
+// ErrInvalidUrgency is returned for an unknown urgency indicator.
+var ErrInvalidUrgency = errors.New("invalid urgency")
+
+// ErrInvalidAckRequest is returned for an unknown acknowledgement request.
+var ErrInvalidAckRequest = errors.New("invalid acknowledgement request")
+
+// ErrInvalidNotification is returned for a notification bitmask with unknown types.
+var ErrInvalidNotification = errors.New("invalid notification type")
+
+// validatePriority checks the urgency, acknowledgement request and notification types of the options.
+func validatePriority(opt *SendOptions) error {
+	if _, ok := urgencyXser[opt.Urgency]; !ok {
+		return ErrInvalidUrgency
+	}
+	if _, ok := ackRequestXser[opt.AckRequest]; !ok {
+		return ErrInvalidAckRequest
+	}
+	if opt.Notifications != NotifyNone && opt.Notifications&^NotifyAll != 0 {
+		return ErrInvalidNotification
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package ucp
+
+import "testing"
+
+func TestSubmitPriority(t *testing.T) {
+	submitPriorityTestCases := []struct {
+		name   string
+		params submitParams
+		nrq    string
+		nt     string
+		xser   string
+	}{
+		{"defaults", submitParams{}, "1", "1", "020100060101070101"},
+		{"marketing", submitParams{urgency: UrgencyBulk, ackRequest: AckNone, notifications: NotifyNone}, "0", "", "020100060100070100"},
+		{"otp", submitParams{urgency: UrgencyUrgent, notifications: NotifyAll}, "1", "7", "020100060102070101"},
+		{"non-delivery and buffered", submitParams{urgency: UrgencyVeryUrgent, ackRequest: AckDeliveryAndManual, notifications: NotifyNonDelivered | NotifyBuffered}, "1", "6", "020100060103070103"},
+	}
+
+	for _, testCase := range submitPriorityTestCases {
+		frame := encodeMessage([]byte("01"), originator{maskSender("Voyager"), oAdCAlphaNum, OriginatorAlphanumeric}, "09495696599", "hi",
+			alphaNumericMessage, "", 0, 1, 1, concatRef8, shift{}, testCase.params)
+		pdu, err := Describe(frame)
+		if err != nil || len(pdu.Problems) > 0 {
+			t.Fatalf("testcase %s: invalid frame %q: %v %v\n", testCase.name, frame, err, pdu.Problems)
+		}
+		nrq, _ := pdu.Field("NRq")
+		nt, _ := pdu.Field("NT")
+		xser, _ := pdu.Field("Xser")
+		if nrq != testCase.nrq || nt != testCase.nt || xser != testCase.xser {
+			t.Errorf("testcase %s: Expected %q %q %q, got %q %q %q\n", testCase.name,
+				testCase.nrq, testCase.nt, testCase.xser, nrq, nt, xser)
+		}
+	}
+}
+
+func TestValidatePriority(t *testing.T) {
+	validatePriorityTestCases := []struct {
+		name     string
+		opt      SendOptions
+		expected error
+	}{
+		{"defaults", SendOptions{}, nil},
+		{"all set", SendOptions{Urgency: UrgencyBulk, AckRequest: AckManual, Notifications: NotifyAll}, nil},
+		{"no notification", SendOptions{Notifications: NotifyNone}, nil},
+		{"unknown urgency", SendOptions{Urgency: Urgency(4)}, ErrInvalidUrgency},
+		{"unknown ack request", SendOptions{AckRequest: AckRequest(-1)}, ErrInvalidAckRequest},
+		{"unknown notification type", SendOptions{Notifications: Notification(8)}, ErrInvalidNotification},
+	}
+
+	for _, testCase := range validatePriorityTestCases {
+		if err := validatePriority(&testCase.opt); err != testCase.expected {
+			t.Errorf("testcase %s: Expected %v, got %v\n", testCase.name, testCase.expected, err)
+		}
+	}
+}
@@ -8,7 +8,7 @@ import (
 
 // readLoop reads incoming messages from the SMSC using the underlying bufio.Reader
 func readLoop(reader *bufio.Reader, wg *sync.WaitGroup, closeChan chan struct{},
-	submitSmRespCh, deliverNotifCh, deliverMsgCh chan []string, logger Logger) {
+	submitSmRespCh, deliverNotifCh, deliverMsgCh, alertRespCh chan []string, hook FrameHook, logger Logger) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -26,6 +26,7 @@ func readLoop(reader *bufio.Reader, wg *sync.WaitGroup, closeChan chan struct{},
 					}
 					continue
 				}
+				hook.trace(Inbound, []byte(readData))
 				opType, fields, err := parseResp(readData)
 				if err != nil {
 					continue
@@ -42,6 +43,11 @@ func readLoop(reader *bufio.Reader, wg *sync.WaitGroup, closeChan chan struct{},
 					deliverMsgCh <- fields
 				case opAlert:
 					logger.Printf("opAlert: %q\n", fields)
+					// keep-alive responses are not waited for, never block on them
+					select {
+					case alertRespCh <- fields:
+					default:
+					}
 				default:
 					logger.Printf("unknown operationType: %q\n", fields)
 				}
@@ -19,7 +19,7 @@ func TestReaderSubmitSm(t *testing.T) {
 	wg := new(sync.WaitGroup)
 	closeChan := make(chan struct{}, 1)
 	submitSmRespCh := make(chan []string, 1)
-	readLoop(reader, wg, closeChan, submitSmRespCh, nil, nil,
+	readLoop(reader, wg, closeChan, submitSmRespCh, nil, nil, nil, nil,
 		&Client{logger: log.New(os.Stdout, "debug ", 0)})
 	runtime.Gosched()
 	actual := <-submitSmRespCh
@@ -56,7 +56,7 @@ func TestReaderDeliverNotif(t *testing.T) {
 	wg := new(sync.WaitGroup)
 	closeChan := make(chan struct{}, 1)
 	deliverNotifCh := make(chan []string, 1)
-	readLoop(reader, wg, closeChan, nil, deliverNotifCh, nil,
+	readLoop(reader, wg, closeChan, nil, deliverNotifCh, nil, nil, nil,
 		&Client{logger: log.New(os.Stdout, "debug ", 0)})
 	runtime.Gosched()
 	actual := <-deliverNotifCh
@@ -100,7 +100,7 @@ func TestReaderDeliverMsg(t *testing.T) {
 	wg := new(sync.WaitGroup)
 	closeChan := make(chan struct{}, 1)
 	deliverMsgCh := make(chan []string, 1)
-	readLoop(reader, wg, closeChan, nil, nil, deliverMsgCh,
+	readLoop(reader, wg, closeChan, nil, nil, deliverMsgCh, nil, nil,
 		&Client{logger: log.New(os.Stdout, "debug ", 0)})
 	runtime.Gosched()
 	actual := <-deliverMsgCh
@@ -0,0 +1,235 @@
+package ucp
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MessagePart is a part of a multipart mobile-originating message waiting for reassembly.
+type MessagePart struct {
+	Sender    string `json:"sender"`
+	Receiver  string `json:"receiver"`
+	MessageID string `json:"message_id"`
+	// DCS is the data coding scheme extra service of the part.
+	DCS string `json:"dcs"`
+	// Message is the hex encoded user data of the part.
+	Message string `json:"message"`
+	// Ref is the concatenated message reference number.
+	Ref int `json:"ref"`
+	// Part is the number of the part, starting at 1.
+	Part int `json:"part"`
+	// Parts is the total number of parts of the message.
+	Parts int `json:"parts"`
+	// Received is the time the part was received.
+	Received time.Time `json:"received"`
+	// LockingShift and SingleShift are the national language tables of a GSM 7-bit part.
+	LockingShift Language `json:"locking_shift,omitempty"`
+	SingleShift  Language `json:"single_shift,omitempty"`
+}
+
+// ReassemblyStore keeps the parts of multipart mobile-originating messages until all of them arrive.
+// Parts are added before they are acknowledged to the SMSC, so a store that persists them
+// lets a restarted client complete the messages whose remaining parts arrive after the restart.
+// Implementations must be safe for concurrent use.
+type ReassemblyStore interface {
+	// Add stores a part under the key of its message.
+	// It reports false if the part number was already stored.
+	Add(key string, part MessagePart) (bool, error)
+	// Parts returns the parts stored under key, sorted by part number.
+	Parts(key string) ([]MessagePart, error)
+	// Remove forgets the parts stored under key.
+	Remove(key string) error
+	// Pending returns the keys of the stored messages with the time their first part was received.
+	Pending() (map[string]time.Time, error)
+}
+
+// MemoryReassemblyStore is a ReassemblyStore that keeps the parts in memory.
+type MemoryReassemblyStore struct {
+	mu    sync.Mutex
+	parts map[string][]MessagePart
+}
+
+// NewMemoryReassemblyStore returns an empty MemoryReassemblyStore.
+func NewMemoryReassemblyStore() *MemoryReassemblyStore {
+	return &MemoryReassemblyStore{parts: make(map[string][]MessagePart)}
+}
+
+// Add implements ReassemblyStore.
+func (s *MemoryReassemblyStore) Add(key string, part MessagePart) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(key, part), nil
+}
+
+// add inserts part in the sorted parts of key. s.mu must be held.
+func (s *MemoryReassemblyStore) add(key string, part MessagePart) bool {
+	parts := s.parts[key]
+	i := sort.Search(len(parts), func(i int) bool { return parts[i].Part >= part.Part })
+	if i < len(parts) && parts[i].Part == part.Part {
+		return false
+	}
+	parts = append(parts, MessagePart{})
+	copy(parts[i+1:], parts[i:])
+	parts[i] = part
+	s.parts[key] = parts
+	return true
+}
+
+// Parts implements ReassemblyStore.
+func (s *MemoryReassemblyStore) Parts(key string) ([]MessagePart, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]MessagePart(nil), s.parts[key]...), nil
+}
+
+// Remove implements ReassemblyStore.
+func (s *MemoryReassemblyStore) Remove(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.parts, key)
+	return nil
+}
+
+// Pending implements ReassemblyStore.
+func (s *MemoryReassemblyStore) Pending() (map[string]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := make(map[string]time.Time, len(s.parts))
+	for key, parts := range s.parts {
+		first := parts[0].Received
+		for _, part := range parts[1:] {
+			if part.Received.Before(first) {
+				first = part.Received
+			}
+		}
+		pending[key] = first
+	}
+	return pending, nil
+}
+
+// FileReassemblyStore is a ReassemblyStore that survives restarts.
+// The parts of every message are appended as JSON lines to a file of their own
+// in a directory, and the file is removed once the message is reassembled.
+type FileReassemblyStore struct {
+	dir string
+	// mu guards the files and mem
+	mu  sync.Mutex
+	mem *MemoryReassemblyStore
+}
+
+// partFileExt is the extension of the files of a FileReassemblyStore.
+const partFileExt = ".parts"
+
+// OpenFileReassemblyStore opens the store kept in dir, creating the directory if needed,
+// and loads the parts stored by a previous process.
+func OpenFileReassemblyStore(dir string) (*FileReassemblyStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &FileReassemblyStore{dir: dir, mem: NewMemoryReassemblyStore()}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range files {
+		name := fi.Name()
+		if !strings.HasSuffix(name, partFileExt) {
+			continue
+		}
+		key, err := hex.DecodeString(strings.TrimSuffix(name, partFileExt))
+		if err != nil {
+			continue
+		}
+		if err := s.load(string(key), filepath.Join(dir, name)); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// load reads the parts of a message file into memory.
+func (s *FileReassemblyStore) load(key, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var part MessagePart
+		if err := json.Unmarshal(scanner.Bytes(), &part); err != nil {
+			// skip a line torn by a crash
+			continue
+		}
+		s.mem.add(key, part)
+	}
+	return scanner.Err()
+}
+
+// path returns the file of the message stored under key, keys are hex encoded to make safe file names.
+func (s *FileReassemblyStore) path(key string) string {
+	return filepath.Join(s.dir, hex.EncodeToString([]byte(key))+partFileExt)
+}
+
+// Add implements ReassemblyStore. The part is synced to disk before Add returns.
+func (s *FileReassemblyStore) Add(key string, part MessagePart) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, stored := range s.mem.parts[key] {
+		if stored.Part == part.Part {
+			return false, nil
+		}
+	}
+	b, err := json.Marshal(part)
+	if err != nil {
+		return false, err
+	}
+	file, err := os.OpenFile(s.path(key), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return false, err
+	}
+	if _, err := file.Write(append(b, '\n')); err != nil {
+		file.Close()
+		return false, err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return false, err
+	}
+	if err := file.Close(); err != nil {
+		return false, err
+	}
+	return s.mem.add(key, part), nil
+}
+
+// Parts implements ReassemblyStore.
+func (s *FileReassemblyStore) Parts(key string) ([]MessagePart, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mem.Parts(key)
+}
+
+// Remove implements ReassemblyStore.
+func (s *FileReassemblyStore) Remove(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return s.mem.Remove(key)
+}
+
+// Pending implements ReassemblyStore.
+func (s *FileReassemblyStore) Pending() (map[string]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mem.Pending()
+}
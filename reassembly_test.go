@@ -0,0 +1,77 @@
+package ucp
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFileReassemblyStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ucp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	received := time.Date(2017, 9, 29, 18, 25, 23, 0, time.UTC)
+	part := func(n int) MessagePart {
+		return MessagePart{
+			Sender:    "09191234567",
+			Receiver:  "2371",
+			MessageID: "09191234567:290917182523",
+			DCS:       "00",
+			Message:   "50415254",
+			Ref:       109,
+			Part:      n,
+			Parts:     3,
+			Received:  received.Add(time.Duration(n) * time.Second),
+		}
+	}
+	store, err := OpenFileReassemblyStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testcases := []struct {
+		key   string
+		part  MessagePart
+		added bool
+	}{
+		{"09191234567:2371:109", part(3), true},
+		{"09191234567:2371:109", part(1), true},
+		{"09191234567:2371:109", part(1), false},
+		{"09191234568:2371:7", part(2), true},
+	}
+	for _, tc := range testcases {
+		if added, err := store.Add(tc.key, tc.part); err != nil || added != tc.added {
+			t.Errorf("Add(%q, part %d): expected %v, got %v %v", tc.key, tc.part.Part, tc.added, added, err)
+		}
+	}
+	if err := store.Remove("09191234568:2371:7"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new process finds the parts left by the previous one
+	store, err = OpenFileReassemblyStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts, err := store.Parts("09191234567:2371:109")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []MessagePart{part(1), part(3)}; !reflect.DeepEqual(expected, parts) {
+		t.Errorf("Expected %+v, got %+v", expected, parts)
+	}
+	pending, err := store.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]time.Time{"09191234567:2371:109": part(1).Received}; !reflect.DeepEqual(expected, pending) {
+		t.Errorf("Expected %v, got %v", expected, pending)
+	}
+	if missing := missingParts(parts); !reflect.DeepEqual(missing, []int{2}) {
+		t.Errorf("Expected missing part 2, got %v", missing)
+	}
+}
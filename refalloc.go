@@ -0,0 +1,90 @@
+package ucp
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// refRetention is how long a concatenation reference stays reserved for its destination.
+// Handsets give up reassembling a multipart message well before that.
+const refRetention = time.Hour
+
+// RefAllocator hands out the concatenation references of multipart messages.
+// References are sequential per destination, and a reference used for a destination
+// is not handed out again for it within an hour, unless all of them were used since.
+// A RefAllocator can be shared by the clients sending to the same destinations.
+type RefAllocator struct {
+	bits int
+	// mu guards the fields below
+	mu           sync.Mutex
+	destinations map[string]*destinationRefs
+	lastPrune    time.Time
+}
+
+// destinationRefs are the references recently used for a destination.
+type destinationRefs struct {
+	next int
+	used map[int]time.Time
+}
+
+// NewRefAllocator returns a RefAllocator of 8-bit or 16-bit references.
+// Any bits other than 16 selects 8-bit references.
+func NewRefAllocator(bits int) *RefAllocator {
+	if bits != concatRef16 {
+		bits = concatRef8
+	}
+	return &RefAllocator{
+		bits:         bits,
+		destinations: make(map[string]*destinationRefs),
+		lastPrune:    time.Now(),
+	}
+}
+
+// Bits returns the width of the references, 8 or 16.
+func (a *RefAllocator) Bits() int {
+	return a.bits
+}
+
+// Next returns the next reference for the destination.
+func (a *RefAllocator) Next(receiver string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	if now.Sub(a.lastPrune) > refRetention {
+		a.prune(now)
+	}
+	size := 1 << uint(a.bits)
+	d, ok := a.destinations[receiver]
+	if !ok {
+		// start at a random reference, a restarted client must not repeat the last ones
+		d = &destinationRefs{next: rand.Intn(size), used: make(map[int]time.Time)}
+		a.destinations[receiver] = d
+	}
+	ref := d.next
+	for i := 0; i < size; i++ {
+		candidate := (d.next + i) % size
+		if used, ok := d.used[candidate]; !ok || now.Sub(used) > refRetention {
+			ref = candidate
+			break
+		}
+	}
+	d.used[ref] = now
+	d.next = (ref + 1) % size
+	return ref
+}
+
+// prune forgets the references used longer than refRetention ago. a.mu must be held.
+func (a *RefAllocator) prune(now time.Time) {
+	for receiver, d := range a.destinations {
+		for ref, used := range d.used {
+			if now.Sub(used) > refRetention {
+				delete(d.used, ref)
+			}
+		}
+		if len(d.used) == 0 {
+			delete(a.destinations, receiver)
+		}
+	}
+	a.lastPrune = now
+}
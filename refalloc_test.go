@@ -0,0 +1,59 @@
+package ucp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefAllocator(t *testing.T) {
+	testcases := []struct {
+		bits int
+		size int
+	}{
+		{concatRef8, 256},
+		{concatRef16, 65536},
+		{0, 256},
+	}
+	for _, tc := range testcases {
+		a := NewRefAllocator(tc.bits)
+		first := a.Next("09191234567")
+		seen := map[int]bool{first: true}
+		for i := 1; i < tc.size; i++ {
+			ref := a.Next("09191234567")
+			if ref != (first+i)%tc.size {
+				t.Fatalf("bits %d: expected sequential reference %d, got %d", tc.bits, (first+i)%tc.size, ref)
+			}
+			seen[ref] = true
+		}
+		if len(seen) != tc.size {
+			t.Errorf("bits %d: expected %d distinct references, got %d", tc.bits, tc.size, len(seen))
+		}
+		// every reference was used, the oldest is reused
+		if ref := a.Next("09191234567"); ref != first {
+			t.Errorf("bits %d: expected the oldest reference %d to be reused, got %d", tc.bits, first, ref)
+		}
+	}
+}
+
+func TestRefAllocatorSkipsRecentRefs(t *testing.T) {
+	a := NewRefAllocator(concatRef8)
+	a.destinations["09191234567"] = &destinationRefs{
+		next: 10,
+		used: map[int]time.Time{
+			10: time.Now(),
+			11: time.Now(),
+			12: time.Now().Add(-2 * refRetention),
+		},
+	}
+	if ref := a.Next("09191234567"); ref != 12 {
+		t.Errorf("Expected the expired reference 12, got %d", ref)
+	}
+	if ref := a.Next("09191234567"); ref != 13 {
+		t.Errorf("Expected reference 13, got %d", ref)
+	}
+	// destinations are independent
+	a.destinations["09191234568"] = &destinationRefs{next: 10, used: map[int]time.Time{}}
+	if ref := a.Next("09191234568"); ref != 10 {
+		t.Errorf("Expected reference 10 for another destination, got %d", ref)
+	}
+}
@@ -0,0 +1,72 @@
+package ucp
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidValidityPeriod is returned when a validity period is out of the range of the SMSC.
+var ErrInvalidValidityPeriod = errors.New("invalid validity period")
+
+// ErrInvalidDeliveryTime is returned when a scheduled delivery time is out of the range of the SMSC.
+var ErrInvalidDeliveryTime = errors.New("invalid delivery time")
+
+const (
+	// emiTimeLayout is the DDMMYYhhmm format of the DDT and VP fields
+	emiTimeLayout = "0201061504"
+	// minValidityPeriod is the resolution of the VP field
+	minValidityPeriod = time.Minute
+	// maxValidityPeriod is the longest validity period of a short message, 63 weeks (3GPP TS 23.040, 9.2.3.12)
+	maxValidityPeriod = 63 * 7 * 24 * time.Hour
+	// deferredDelivery is the DD field of a scheduled message
+	deferredDelivery = "1"
+)
+
+// submitParams are the per-message fields of a submit packet.
+type submitParams struct {
+	// dd is the deferred delivery request, set with ddt
+	dd string
+	// ddt is the scheduled delivery time
+	ddt string
+	// vp is the time the message expires
+	vp string
+	// urgency, ackRequest and notifications are the priority options of the message
+	urgency       Urgency
+	ackRequest    AckRequest
+	notifications Notification
+}
+
+// newSubmitParams validates and formats the validity period and delivery time of the options
+// in the timezone of the SMSC. The validity period counts from the delivery time, if any, else from now.
+// The priority options are validated and copied as they are.
+func newSubmitParams(opt *SendOptions, now time.Time, loc *time.Location) (submitParams, error) {
+	if err := validatePriority(opt); err != nil {
+		return submitParams{}, err
+	}
+	params := submitParams{urgency: opt.Urgency, ackRequest: opt.AckRequest, notifications: opt.Notifications}
+	start := now
+	if !opt.DeliverAt.IsZero() {
+		if !opt.DeliverAt.After(now) || opt.DeliverAt.Sub(now) > maxValidityPeriod {
+			return submitParams{}, ErrInvalidDeliveryTime
+		}
+		start = opt.DeliverAt
+		params.dd, params.ddt = deferredDelivery, emiTime(start, loc)
+	}
+	if opt.ValidityPeriod != 0 {
+		if opt.ValidityPeriod < minValidityPeriod || opt.ValidityPeriod > maxValidityPeriod {
+			return submitParams{}, ErrInvalidValidityPeriod
+		}
+		params.vp = emiTime(start.Add(opt.ValidityPeriod), loc)
+	}
+	return params, nil
+}
+
+// emiTime formats t in the DDMMYYhhmm format in loc, rounded up to the minute
+// so that a message is neither delivered early nor expires early.
+func emiTime(t time.Time, loc *time.Location) string {
+	t = t.In(loc)
+	if rounded := t.Truncate(time.Minute); rounded.Before(t) {
+		t = rounded.Add(time.Minute)
+	}
+	return t.Format(emiTimeLayout)
+}
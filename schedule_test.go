@@ -0,0 +1,45 @@
+package ucp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSubmitParams(t *testing.T) {
+	now := time.Date(2026, 10, 19, 10, 2, 30, 0, time.UTC)
+	manila := time.FixedZone("PHT", 8*60*60)
+	nineAM := time.Date(2026, 10, 20, 9, 0, 0, 0, manila)
+	submitParamsTestCases := []struct {
+		name     string
+		opt      SendOptions
+		expected submitParams
+		err      error
+	}{
+		{"none", SendOptions{}, submitParams{}, nil},
+		{"validity period rounded up", SendOptions{ValidityPeriod: 5 * time.Minute}, submitParams{vp: "1910261808"}, nil},
+		{"scheduled", SendOptions{DeliverAt: nineAM}, submitParams{dd: "1", ddt: "2010260900"}, nil},
+		{"validity from the delivery time", SendOptions{DeliverAt: nineAM, ValidityPeriod: time.Hour}, submitParams{dd: "1", ddt: "2010260900", vp: "2010261000"}, nil},
+		{"validity period too short", SendOptions{ValidityPeriod: 30 * time.Second}, submitParams{}, ErrInvalidValidityPeriod},
+		{"validity period too long", SendOptions{ValidityPeriod: 64 * 7 * 24 * time.Hour}, submitParams{}, ErrInvalidValidityPeriod},
+		{"negative validity period", SendOptions{ValidityPeriod: -time.Hour}, submitParams{}, ErrInvalidValidityPeriod},
+		{"delivery in the past", SendOptions{DeliverAt: now.Add(-time.Minute)}, submitParams{}, ErrInvalidDeliveryTime},
+		{"delivery too far", SendOptions{DeliverAt: now.Add(64 * 7 * 24 * time.Hour)}, submitParams{}, ErrInvalidDeliveryTime},
+	}
+
+	for _, testCase := range submitParamsTestCases {
+		actual, err := newSubmitParams(&testCase.opt, now, manila)
+		if actual != testCase.expected || err != testCase.err {
+			t.Errorf("testcase %s: Expected %+v %v, got %+v %v\n", testCase.name, testCase.expected, testCase.err, actual, err)
+		}
+	}
+}
+
+func TestSubmitScheduled(t *testing.T) {
+	params := submitParams{dd: "1", ddt: "2010260900", vp: "2010261000"}
+	actual := string(encodeMessage([]byte("01"), originator{maskSender("Voyager"), oAdCAlphaNum, OriginatorAlphanumeric}, "09495696599", "hi",
+		alphaNumericMessage, "", 0, 1, 1, concatRef8, shift{}, params))
+	if expected := "//1/2010260900/2010261000//"; !strings.Contains(actual, expected) {
+		t.Errorf("Expected %s in %s\n", expected, actual)
+	}
+}
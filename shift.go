@@ -0,0 +1,371 @@
+package ucp
+
+import (
+	"strconv"
+
+	"github.com/go-gsm/charset"
+)
+
+// Language is a national language identifier of the shift tables of the GSM 7-bit alphabet
+// (3GPP TS 23.038, 6.2.1.2.4). Zero is the default alphabet and its extension table.
+type Language byte
+
+// National language identifiers.
+const (
+	LanguageTurkish    Language = 1
+	LanguageSpanish    Language = 2
+	LanguagePortuguese Language = 3
+	LanguageBengali    Language = 4
+	LanguageGujarati   Language = 5
+	LanguageHindi      Language = 6
+	LanguageKannada    Language = 7
+	LanguageMalayalam  Language = 8
+	LanguageOriya      Language = 9
+	LanguagePunjabi    Language = 10
+	LanguageTamil      Language = 11
+	LanguageTelugu     Language = 12
+	LanguageUrdu       Language = 13
+)
+
+var languageNames = map[Language]string{
+	LanguageTurkish:    "Turkish",
+	LanguageSpanish:    "Spanish",
+	LanguagePortuguese: "Portuguese",
+	LanguageBengali:    "Bengali",
+	LanguageGujarati:   "Gujarati",
+	LanguageHindi:      "Hindi",
+	LanguageKannada:    "Kannada",
+	LanguageMalayalam:  "Malayalam",
+	LanguageOriya:      "Oriya",
+	LanguagePunjabi:    "Punjabi",
+	LanguageTamil:      "Tamil",
+	LanguageTelugu:     "Telugu",
+	LanguageUrdu:       "Urdu",
+}
+
+func (l Language) String() string {
+	if l == 0 {
+		return "default"
+	}
+	if name, ok := languageNames[l]; ok {
+		return name
+	}
+	return "Language(" + strconv.Itoa(int(l)) + ")"
+}
+
+// escape is the septet preceding a character of a single shift table.
+const escape = 0x1B
+
+// unusedSeptet marks the septets of a locking shift table that have no character.
+const unusedSeptet = '\uFFFD'
+
+// defaultAlphabet is the GSM 7-bit default alphabet, indexed by septet.
+// The escape septet is a placeholder.
+const defaultAlphabet = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞ\x1bÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// lockingShiftTables replace the default alphabet, indexed by septet (3GPP TS 23.038, A.3).
+// Spanish has no locking shift table.
+// Septets without a character in a language are unusedSeptet.
+var lockingShiftTables = map[Language]string{
+	LanguageTurkish: "@£$¥€éùıòÇ\nĞğ\rÅåΔ_ΦΓΛΩΠΨΣΘΞ\x1bŞşßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+		"İABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§çabcdefghijklmnopqrstuvwxyzäöñüà",
+	LanguagePortuguese: "@£$¥êéúíóç\nÔô\rÁáΔ_ªÇÀ∞^\\€Ó|\x1bÂâÊÉ !\"#º%&'()*+,-./0123456789:;<=>?" +
+		"ÍABCDEFGHIJKLMNOPQRSTUVWXYZÃÕÚÜ§~abcdefghijklmnopqrstuvwxyzãõ`üà",
+	LanguageBengali: "ঁংঃঅআইঈউঊঋ\nঌ\uFFFD\r\uFFFDএ" +
+		"ঐ\uFFFD\uFFFDওঔকখগঘঙচ\x1bছজঝঞ" +
+		" !টঠডঢণত)(থদ,ধ.ন" +
+		"0123456789:;\uFFFDপফ?" +
+		"বভমযর\uFFFDল\uFFFD\uFFFD\uFFFDশষসহ়ঽ" +
+		"ািীুূৃৄ\uFFFD\uFFFDেৈ\uFFFD\uFFFDোৌ্" +
+		"ৎabcdefghijklmnopqrstuvwxyzৗড়ঢ়ৰৱ",
+	LanguageGujarati: "ઁંઃઅઆઇઈઉઊઋ\nઌઍ\r\uFFFDએ" +
+		"ઐઑ\uFFFDઓઔકખગઘઙચ\x1bછજઝઞ" +
+		" !ટઠડઢણત)(થદ,ધ.ન" +
+		"0123456789:;\uFFFDપફ?" +
+		"બભમયર\uFFFDલળ\uFFFDવશષસહ઼ઽ" +
+		"ાિીુૂૃૄૅ\uFFFDેૈૉ\uFFFDોૌ્" +
+		"ૐabcdefghijklmnopqrstuvwxyzૠૡૢૣ૱",
+	LanguageHindi: "ँंःअआइईउऊऋ\nऌऍ\rऎए" +
+		"ऐऑऒओऔकखगघङच\x1bछजझञ" +
+		" !टठडढणत)(थद,ध.न" +
+		"0123456789:;ऩपफ?" +
+		"बभमयरऱलळऴवशषसह़ऽ" +
+		"ािीुूृॄॅॆेैॉॊोौ्" +
+		"ॐabcdefghijklmnopqrstuvwxyzॲॻॼॾॿ",
+	LanguageKannada: "\uFFFDಂಃಅಆಇಈಉಊಋ\nಌ\uFFFD\rಎಏ" +
+		"ಐ\uFFFDಒಓಔಕಖಗಘಙಚ\x1bಛಜಝಞ" +
+		" !ಟಠಡಢಣತ)(ಥದ,ಧ.ನ" +
+		"0123456789:;\uFFFDಪಫ?" +
+		"ಬಭಮಯರಱಲಳ\uFFFDವಶಷಸಹ಼ಽ" +
+		"ಾಿೀುೂೃೄ\uFFFDೆೇೈ\uFFFDೊೋೌ್" +
+		"ೕabcdefghijklmnopqrstuvwxyzೖೠೡೢೣ",
+	LanguageMalayalam: "\uFFFDംഃഅആഇഈഉഊഋ\nഌ\uFFFD\rഎഏ" +
+		"ഐ\uFFFDഒഓഔകഖഗഘങച\x1bഛജഝഞ" +
+		" !ടഠഡഢണത)(ഥദ,ധ.ന" +
+		"0123456789:;\uFFFDപഫ?" +
+		"ബഭമയരറലളഴവശഷസഹ\uFFFDഽ" +
+		"ാിീുൂൃൄ\uFFFDെേൈ\uFFFDൊോൌ്" +
+		"ൗabcdefghijklmnopqrstuvwxyzൠൡൢൣ൹",
+	LanguageOriya: "ଁଂଃଅଆଇଈଉଊଋ\nଌ\uFFFD\r\uFFFDଏ" +
+		"ଐ\uFFFD\uFFFDଓଔକଖଗଘଙଚ\x1bଛଜଝଞ" +
+		" !ଟଠଡଢଣତ)(ଥଦ,ଧ.ନ" +
+		"0123456789:;\uFFFDପଫ?" +
+		"ବଭମଯର\uFFFDଲଳ\uFFFDଵଶଷସହ଼ଽ" +
+		"ାିୀୁୂୃୄ\uFFFD\uFFFDେୈ\uFFFD\uFFFDୋୌ୍" +
+		"ୖabcdefghijklmnopqrstuvwxyzୗୠୡୢୣ",
+	LanguagePunjabi: "ਁਂਃਅਆਇਈਉਊ\uFFFD\n\uFFFD\uFFFD\r\uFFFDਏ" +
+		"ਐ\uFFFD\uFFFDਓਔਕਖਗਘਙਚ\x1bਛਜਝਞ" +
+		" !ਟਠਡਢਣਤ)(ਥਦ,ਧ.ਨ" +
+		"0123456789:;\uFFFDਪਫ?" +
+		"ਬਭਮਯਰ\uFFFDਲਲ਼\uFFFDਵਸ਼\uFFFDਸਹ਼\uFFFD" +
+		"ਾਿੀੁੂ\uFFFD\uFFFD\uFFFD\uFFFDੇੈ\uFFFD\uFFFDੋੌ੍" +
+		"ੑabcdefghijklmnopqrstuvwxyzੰੱੲੳੴ",
+	LanguageTamil: "\uFFFDஂஃஅஆஇஈஉஊ\uFFFD\n\uFFFD\uFFFD\rஎஏ" +
+		"ஐ\uFFFDஒஓஔக\uFFFD\uFFFD\uFFFDஙச\x1b\uFFFDஜ\uFFFDஞ" +
+		" !ட\uFFFD\uFFFD\uFFFDணத)(\uFFFD\uFFFD,\uFFFD.ந" +
+		"0123456789:;னப\uFFFD?" +
+		"\uFFFD\uFFFDமயரறலளழவஶஷஸஹ\uFFFD\uFFFD" +
+		"ாிீுூ\uFFFD\uFFFD\uFFFDெேை\uFFFDொோௌ்" +
+		"ௐabcdefghijklmnopqrstuvwxyzௗ௰௱௲௹",
+	LanguageTelugu: "ఁంఃఅఆఇఈఉఊఋ\nఌ\uFFFD\rఎఏ" +
+		"ఐ\uFFFDఒఓఔకఖగఘఙచ\x1bఛజఝఞ" +
+		" !టఠడఢణత)(థద,ధ.న" +
+		"0123456789:;\uFFFDపఫ?" +
+		"బభమయరఱలళ\uFFFDవశషసహ\uFFFDఽ" +
+		"ాిీుూృౄ\uFFFDెేై\uFFFDొోౌ్" +
+		"ౕabcdefghijklmnopqrstuvwxyzౖౠౡౢౣ",
+	LanguageUrdu: "اآبٻڀپڦتۂٿ\nٹٽ\rٺټ" +
+		"ثجځڄڃڅچڇحخد\x1bڌڈډڊ" +
+		" !ڏڍذرڑړ)(ڙز,ږ.ژ" +
+		"0123456789:;ښسش?" +
+		"صضطظعفقکڪګگڳڱلمن" +
+		"ںڻڼوۄەہھءیېےٍُِٗ" +
+		"ٔabcdefghijklmnopqrstuvwxyzّٰٕٖٓ",
+}
+
+// defaultExtension is the extension table of the default alphabet, by septet following the escape.
+var defaultExtension = map[byte]rune{
+	0x0A: '\f', 0x14: '^', 0x28: '{', 0x29: '}', 0x2F: '\\',
+	0x3C: '[', 0x3D: '~', 0x3E: ']', 0x40: '|', 0x65: '€',
+}
+
+// singleShiftTables replace the extension table, by septet following the escape (3GPP TS 23.038, A.2).
+var singleShiftTables = map[Language]map[byte]rune{
+	LanguageTurkish: {
+		0x0A: '\f', 0x14: '^', 0x28: '{', 0x29: '}', 0x2F: '\\',
+		0x3C: '[', 0x3D: '~', 0x3E: ']', 0x40: '|',
+		0x47: 'Ğ', 0x49: 'İ', 0x53: 'Ş', 0x63: 'ç', 0x65: '€', 0x67: 'ğ', 0x69: 'ı', 0x73: 'ş',
+	},
+	LanguageSpanish: {
+		0x09: 'ç', 0x0A: '\f', 0x14: '^', 0x28: '{', 0x29: '}', 0x2F: '\\',
+		0x3C: '[', 0x3D: '~', 0x3E: ']', 0x40: '|',
+		0x41: 'Á', 0x49: 'Í', 0x4F: 'Ó', 0x55: 'Ú', 0x61: 'á', 0x65: '€', 0x69: 'í', 0x6F: 'ó', 0x75: 'ú',
+	},
+	LanguagePortuguese: {
+		0x05: 'ê', 0x09: 'ç', 0x0A: '\f', 0x0B: 'Ô', 0x0C: 'ô', 0x0E: 'Á', 0x0F: 'á',
+		0x12: 'Φ', 0x13: 'Γ', 0x14: '^', 0x15: 'Ω', 0x16: 'Π', 0x17: 'Ψ', 0x18: 'Σ', 0x19: 'Θ', 0x1F: 'Ê',
+		0x28: '{', 0x29: '}', 0x2F: '\\', 0x3C: '[', 0x3D: '~', 0x3E: ']', 0x40: '|',
+		0x41: 'À', 0x49: 'Í', 0x4F: 'Ó', 0x55: 'Ú', 0x5B: 'Ã', 0x5C: 'Õ',
+		0x61: 'Â', 0x65: '€', 0x69: 'í', 0x6F: 'ó', 0x75: 'ú', 0x7B: 'ã', 0x7C: 'õ', 0x7F: 'â',
+	},
+	LanguageBengali:   indicSingleShift("।॥", "০১২৩৪৫৬৭৮৯", "\u09DF\u09E0\u09E1\u09E2\u09E3\u09F2\u09F3\u09F4\u09F5\u09F6\u09F7\u09F8\u09F9\u09FA"),
+	LanguageGujarati:  indicSingleShift("।॥", "૦૧૨૩૪૫૬૭૮૯", ""),
+	LanguageHindi:     indicSingleShift("।॥", "०१२३४५६७८९", "\u0951\u0952\u0953\u0954\u0958\u0959\u095A\u095B\u095C\u095D\u095E\u095F\u0960\u0961\u0962\u0963\u0970\u0971"),
+	LanguageKannada:   indicSingleShift("।॥", "೦೧೨೩೪೫೬೭೮೯", "\u0CDE\u0CF1\u0CF2"),
+	LanguageMalayalam: indicSingleShift("।॥", "൦൧൨൩൪൫൬൭൮൯", "\u0D70\u0D71\u0D72\u0D73\u0D74\u0D75\u0D7A\u0D7B\u0D7C\u0D7D\u0D7E\u0D7F"),
+	LanguageOriya:     indicSingleShift("।॥", "୦୧୨୩୪୫୬୭୮୯", "\u0B5C\u0B5D\u0B5F\u0B70\u0B71"),
+	LanguagePunjabi:   indicSingleShift("।॥", "੦੧੨੩੪੫੬੭੮੯", "\u0A59\u0A5A\u0A5B\u0A5C\u0A5E\u0A75"),
+	LanguageTamil:     indicSingleShift("।॥", "௦௧௨௩௪௫௬௭௮௯", "\u0BF3\u0BF4\u0BF5\u0BF6\u0BF7\u0BF8\u0BFA"),
+	LanguageTelugu:    indicSingleShift("", "౦౧౨౩౪౫౬౭౮౯", "\u0C58\u0C59\u0C78\u0C79\u0C7A\u0C7B\u0C7C\u0C7D\u0C7E\u0C7F"),
+	LanguageUrdu:      indicSingleShift("\u0600\u0601", "۰۱۲۳۴۵۶۷۸۹", "\u060C\u060D\u060E\u060F\u0610\u0611\u0612\u0613\u0614\u061B\u061F\u0640\u0652\u0658\u066B\u066C\u0672\u0673\u06CD\u06D4"),
+}
+
+// indicSingleShift returns a single shift table of the Indic languages or Urdu (3GPP TS 23.038,
+// A.2.4 to A.2.13). They share their punctuation and Latin letters, and differ by the dandas
+// at 0x19 and 0x1A, the digits from 0x1C and the letters filling the free septets in order.
+func indicSingleShift(dandas, digits, letters string) map[byte]rune {
+	table := map[byte]rune{
+		0x00: '@', 0x01: '£', 0x02: '$', 0x03: '¥', 0x04: '¿', 0x05: '"', 0x06: '¤', 0x07: '%',
+		0x08: '&', 0x09: '\'', 0x0A: '\f', 0x0B: '*', 0x0C: '+', 0x0E: '-', 0x0F: '/',
+		0x10: '<', 0x11: '=', 0x12: '>', 0x13: '¡', 0x14: '^', 0x15: '¡', 0x16: '_', 0x17: '#', 0x18: '*',
+		0x28: '{', 0x29: '}', 0x2F: '\\', 0x3C: '[', 0x3D: '~', 0x3E: ']', 0x40: '|', 0x65: '€',
+	}
+	for i := byte(0); i < 26; i++ {
+		table[0x41+i] = rune('A' + i)
+	}
+	fill := func(septets []byte, chars string) {
+		i := 0
+		for _, r := range chars {
+			table[septets[i]] = r
+			i++
+		}
+	}
+	fill([]byte{0x19, 0x1A}, dandas)
+	fill([]byte{0x1C, 0x1D, 0x1E, 0x1F, 0x20, 0x21, 0x22, 0x23, 0x24, 0x25}, digits)
+	fill([]byte{0x26, 0x27, 0x2A, 0x2B, 0x2C, 0x2D, 0x2E, 0x30, 0x31, 0x32, 0x33,
+		0x34, 0x35, 0x36, 0x37, 0x38, 0x39, 0x3A, 0x3B, 0x3F}, letters)
+	return table
+}
+
+// shift is the pair of national language tables a text message is encoded with.
+// The zero shift is the default alphabet and its extension table.
+type shift struct {
+	locking Language
+	single  Language
+}
+
+// supported reports whether the tables of the shift are known.
+func (s shift) supported() bool {
+	if _, ok := lockingShiftTables[s.locking]; s.locking != 0 && !ok {
+		return false
+	}
+	if _, ok := singleShiftTables[s.single]; s.single != 0 && !ok {
+		return false
+	}
+	return true
+}
+
+// lockingTable returns the alphabet of the shift, the default alphabet for unknown languages.
+func (s shift) lockingTable() []rune {
+	if table, ok := lockingShiftTables[s.locking]; ok {
+		return []rune(table)
+	}
+	return []rune(defaultAlphabet)
+}
+
+// singleTable returns the extension table of the shift, the default one for unknown languages.
+func (s shift) singleTable() map[byte]rune {
+	if table, ok := singleShiftTables[s.single]; ok {
+		return table
+	}
+	return defaultExtension
+}
+
+// encode returns the unpacked septets of the message, or false if a character
+// is in neither table of the shift.
+func (s shift) encode(message string) ([]byte, bool) {
+	locking := make(map[rune]byte)
+	for i, r := range s.lockingTable() {
+		if i != escape && r != unusedSeptet {
+			locking[r] = byte(i)
+		}
+	}
+	single := make(map[rune]byte)
+	for septet, r := range s.singleTable() {
+		// a character found twice in the table is encoded with its first septet
+		if previous, ok := single[r]; !ok || septet < previous {
+			single[r] = septet
+		}
+	}
+	septets := make([]byte, 0, len(message))
+	for _, r := range message {
+		if septet, ok := locking[r]; ok {
+			septets = append(septets, septet)
+		} else if septet, ok := single[r]; ok {
+			septets = append(septets, escape, septet)
+		} else {
+			return nil, false
+		}
+	}
+	return septets, true
+}
+
+// decode returns the text of unpacked septets. An escaped septet missing from
+// the single shift table is decoded with the locking shift table.
+func (s shift) decode(septets []byte) string {
+	locking, single := s.lockingTable(), s.singleTable()
+	runes := make([]rune, 0, len(septets))
+	for i := 0; i < len(septets); i++ {
+		if septets[i] == escape && i+1 < len(septets) {
+			i++
+			if r, ok := single[septets[i]]; ok {
+				runes = append(runes, r)
+				continue
+			}
+		}
+		runes = append(runes, locking[septets[i]&0x7F])
+	}
+	return string(runes)
+}
+
+// septets returns the number of septets of a character, or 0 if the shift cannot encode it.
+func (s shift) septets(r rune) int {
+	if s == (shift{}) {
+		if !charset.IsGsmAlpha(string(r)) {
+			return 0
+		}
+		return gsmSeptets(r)
+	}
+	septets, ok := s.encode(string(r))
+	if !ok {
+		return 0
+	}
+	return len(septets)
+}
+
+// udh returns the national language elements of the shift.
+func (s shift) udh() UDH {
+	udh := make(UDH, 0)
+	if s.single != 0 {
+		udh = append(udh, NationalSingleShift(byte(s.single)))
+	}
+	if s.locking != 0 {
+		udh = append(udh, NationalLockingShift(byte(s.locking)))
+	}
+	return udh
+}
+
+// capacity returns the septets left for the text of a single part and of the parts
+// of a multipart message, by the user data header of the shift.
+// The header takes its length in octets multiplied by 8/7, rounded up.
+func (s shift) capacity(refBits int) (single, multi int) {
+	udh := s.udh()
+	single = gsmMaxSinglePart - udhSeptets(udh.Len())
+	multi = gsmMaxSinglePart - udhSeptets(append(udh, Concatenation(0, 0, 0, refBits)).Len())
+	return single, multi
+}
+
+// udhSeptets returns the number of septets taken by a user data header of n octets.
+func udhSeptets(n int) int {
+	return (n*8 + 6) / 7
+}
+
+// parts splits a message the shift can encode in parts of at most its capacity.
+func (s shift) parts(message string, refBits int) []string {
+	single, multi := s.capacity(refBits)
+	return septetParts(message, single, multi, s.septets)
+}
+
+// chooseShift returns the message type, shift and parts a message is sent with.
+// The national languages are tried for messages the default alphabet cannot encode,
+// or encodes in more parts, and the shift with the fewest parts wins.
+func chooseShift(message string, refBits int, languages []Language) (string, shift, []string) {
+	msgType, best, parts := getMessageType(message), shift{}, getMessageParts(message, refBits)
+	for _, lang := range languages {
+		for _, s := range []shift{{single: lang}, {locking: lang}, {locking: lang, single: lang}} {
+			if !s.supported() {
+				continue
+			}
+			if _, ok := s.encode(message); !ok {
+				continue
+			}
+			if shiftParts := s.parts(message, refBits); msgType == transparentData || len(shiftParts) < len(parts) {
+				msgType, best, parts = alphaNumericMessage, s, shiftParts
+			}
+		}
+	}
+	return msgType, best, parts
+}
+
+// shiftFromUDH returns the shift of the national language elements of a user data header.
+func shiftFromUDH(udh UDH) shift {
+	var s shift
+	if ie, ok := udh.Find(IEINationalLockingShift); ok && len(ie.Data) == 1 {
+		s.locking = Language(ie.Data[0])
+	}
+	if ie, ok := udh.Find(IEINationalSingleShift); ok && len(ie.Data) == 1 {
+		s.single = Language(ie.Data[0])
+	}
+	return s
+}
@@ -0,0 +1,188 @@
+package ucp
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestShiftTables(t *testing.T) {
+	if n := len([]rune(defaultAlphabet)); n != 128 {
+		t.Errorf("default alphabet: Expected 128 characters, got %d\n", n)
+	}
+	for lang, table := range lockingShiftTables {
+		if n := len([]rune(table)); n != 128 {
+			t.Errorf("%v locking shift table: Expected 128 characters, got %d\n", lang, n)
+		}
+	}
+	hindi := []rune(lockingShiftTables[LanguageHindi])
+	for septet, expected := range map[int]rune{0x00: 0x0901, 0x1A: 0x091A, 0x2F: 0x0928, 0x4F: 0x093D, 0x5F: 0x094D, 0x60: 0x0950, 0x7F: 0x097F} {
+		if hindi[septet] != expected {
+			t.Errorf("Hindi septet %02X: Expected %U, got %U\n", septet, expected, hindi[septet])
+		}
+	}
+	singleShiftTestCases := []struct {
+		lang     Language
+		septet   byte
+		expected rune
+	}{
+		{LanguageHindi, 0x19, '।'},
+		{LanguageHindi, 0x1C, '०'},
+		{LanguageHindi, 0x2C, 0x0958},
+		{LanguageHindi, 0x3A, 0x0971},
+		{LanguageBengali, 0x26, 0x09DF},
+		{LanguageTelugu, 0x1C, 0x0C66},
+		{LanguageUrdu, 0x19, 0x0600},
+		{LanguageUrdu, 0x3F, '۔'},
+	}
+	for _, testCase := range singleShiftTestCases {
+		if actual := singleShiftTables[testCase.lang][testCase.septet]; actual != testCase.expected {
+			t.Errorf("%v single shift septet %02X: Expected %U, got %U\n", testCase.lang, testCase.septet, testCase.expected, actual)
+		}
+	}
+}
+
+func TestShiftEncode(t *testing.T) {
+	shiftEncodeTestCases := []struct {
+		name     string
+		shift    shift
+		input    string
+		expected []byte
+		ok       bool
+	}{
+		{"turkish locking shift", shift{locking: LanguageTurkish}, "Ğİ ş", []byte{0x0B, 0x40, 0x20, 0x1D}, true},
+		{"turkish single shift", shift{single: LanguageTurkish}, "Ğİ ş", []byte{0x1B, 0x47, 0x1B, 0x49, 0x20, 0x1B, 0x73}, true},
+		{"default extension with a locking shift", shift{locking: LanguageTurkish}, "€{", []byte{0x04, 0x1B, 0x28}, true},
+		{"portuguese", shift{locking: LanguagePortuguese, single: LanguagePortuguese}, "São ^", []byte{0x53, 0x7B, 0x6F, 0x20, 0x16}, true},
+		{"hindi", shift{locking: LanguageHindi}, "नमस्ते", []byte{0x2F, 0x42, 0x4C, 0x5F, 0x27, 0x59}, true},
+		{"not in the tables", shift{single: LanguageSpanish}, "ş", nil, false},
+	}
+
+	for _, testCase := range shiftEncodeTestCases {
+		actual, ok := testCase.shift.encode(testCase.input)
+		if ok != testCase.ok || !reflect.DeepEqual(testCase.expected, actual) {
+			t.Errorf("testcase %s: Expected %X %v, got %X %v\n", testCase.name, testCase.expected, testCase.ok, actual, ok)
+			continue
+		}
+		if ok {
+			if decoded := testCase.shift.decode(actual); decoded != testCase.input {
+				t.Errorf("testcase %s: Expected %s, got %s\n", testCase.name, testCase.input, decoded)
+			}
+		}
+	}
+}
+
+func TestShiftCapacity(t *testing.T) {
+	shiftCapacityTestCases := []struct {
+		shift   shift
+		refBits int
+		single  int
+		multi   int
+	}{
+		{shift{}, concatRef8, 160, 153},
+		{shift{}, concatRef16, 160, 152},
+		{shift{single: LanguageTurkish}, concatRef8, 155, 149},
+		{shift{locking: LanguageTurkish, single: LanguageTurkish}, concatRef8, 152, 146},
+		{shift{locking: LanguageTurkish, single: LanguageTurkish}, concatRef16, 152, 145},
+	}
+
+	for _, testCase := range shiftCapacityTestCases {
+		single, multi := testCase.shift.capacity(testCase.refBits)
+		if single != testCase.single || multi != testCase.multi {
+			t.Errorf("shift %+v: Expected %d %d, got %d %d\n", testCase.shift, testCase.single, testCase.multi, single, multi)
+		}
+	}
+}
+
+func TestChooseShift(t *testing.T) {
+	chooseShiftTestCases := []struct {
+		name      string
+		input     string
+		languages []Language
+		msgType   string
+		shift     shift
+		parts     int
+	}{
+		{"default alphabet", "hello", []Language{LanguageTurkish}, alphaNumericMessage, shift{}, 1},
+		{"no languages", "Şişli'de buluşalım", nil, transparentData, shift{}, 1},
+		{"turkish", "Şişli'de buluşalım", []Language{LanguageTurkish}, alphaNumericMessage, shift{single: LanguageTurkish}, 1},
+		{"spanish has no locking shift", "¿Qué tal, Ángel?", []Language{LanguageSpanish}, alphaNumericMessage, shift{single: LanguageSpanish}, 1},
+		{"first language that encodes the message", "São João", []Language{LanguageTurkish, LanguagePortuguese}, alphaNumericMessage, shift{single: LanguagePortuguese}, 1},
+		{"locking shift saves parts", strings.Repeat("^", 150), []Language{LanguagePortuguese}, alphaNumericMessage, shift{locking: LanguagePortuguese}, 1},
+		{"hindi", "नमस्ते", []Language{LanguageHindi}, alphaNumericMessage, shift{locking: LanguageHindi}, 1},
+		{"hindi with a danda", "नमस्ते दुनिया।", []Language{LanguageHindi}, alphaNumericMessage, shift{locking: LanguageHindi, single: LanguageHindi}, 1},
+		{"languages without tables are ignored", "নমস্কার", []Language{Language(14)}, transparentData, shift{}, 1},
+		{"not in the tables", "Привет", []Language{LanguageTurkish}, transparentData, shift{}, 1},
+	}
+
+	for _, testCase := range chooseShiftTestCases {
+		msgType, sh, parts := chooseShift(testCase.input, concatRef8, testCase.languages)
+		if msgType != testCase.msgType || sh != testCase.shift || len(parts) != testCase.parts {
+			t.Errorf("testcase %s: Expected %s %+v %d, got %s %+v %d\n", testCase.name,
+				testCase.msgType, testCase.shift, testCase.parts, msgType, sh, len(parts))
+		}
+	}
+}
+
+func TestShiftRoundTrip(t *testing.T) {
+	shiftRoundTripTestCases := []struct {
+		lang  Language
+		input string
+	}{
+		{LanguageBengali, "নমস্কার।"},
+		{LanguageGujarati, "નમસ્તે।"},
+		{LanguageHindi, "नमस्ते दुनिया।"},
+		{LanguageKannada, "ನಮಸ್ಕಾರ।"},
+		{LanguageMalayalam, "നമസ്കാരം।"},
+		{LanguageOriya, "ନମସ୍କାର।"},
+		{LanguagePunjabi, "ਸਤਿ ਸ੍ਰੀ ਅਕਾਲ।"},
+		{LanguageTamil, "வணக்கம் ௧௨௩"},
+		{LanguageTelugu, "నమస్కారం ౧౨"},
+		{LanguageUrdu, "السلام علیکم۔"},
+	}
+
+	for _, testCase := range shiftRoundTripTestCases {
+		msgType, sh, parts := chooseShift(testCase.input, concatRef8, []Language{testCase.lang})
+		expected := shift{locking: testCase.lang, single: testCase.lang}
+		if msgType != alphaNumericMessage || sh != expected || len(parts) != 1 {
+			t.Errorf("%v: Expected %s %+v 1, got %s %+v %d\n", testCase.lang, alphaNumericMessage, expected, msgType, sh, len(parts))
+			continue
+		}
+		septets, _ := sh.encode(parts[0])
+		// an incoming message carries its shift in the UDH and its septets in hex
+		mo := encodeDeliverMsg(strings.ToUpper(hex.EncodeToString(septets)), dcsXserASCII, shiftFromUDH(sh.udh()))
+		if mo != testCase.input {
+			t.Errorf("%v: Expected %s, got %s\n", testCase.lang, testCase.input, mo)
+		}
+	}
+}
+
+func TestShiftFromUDH(t *testing.T) {
+	udh, err := ParseUDH([]byte{0x06, 0x24, 0x01, 0x03, 0x25, 0x01, 0x01})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n", err)
+	}
+	expected := shift{locking: LanguageTurkish, single: LanguagePortuguese}
+	if actual := shiftFromUDH(udh); actual != expected {
+		t.Errorf("Expected %+v, got %+v\n", expected, actual)
+	}
+}
+
+func TestSubmitNationalLanguage(t *testing.T) {
+	actual := string(encodeMessage([]byte("01"), originator{maskSender("Voyager"), oAdCAlphaNum, OriginatorAlphanumeric}, "09495696599", "ş",
+		alphaNumericMessage, "", 0, 1, 1, concatRef8, shift{single: LanguageTurkish}, submitParams{}))
+	for _, expected := range []string{"/3/16/1B73/", "//020100010403240101060101070101///"} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected %s in %s\n", expected, actual)
+		}
+	}
+}
+
+func TestClientAnalyzeNationalLanguage(t *testing.T) {
+	client := &Client{concatRefBits: concatRef8, languages: []Language{LanguageTurkish}}
+	expected := Segmentation{EncodingGSM7, 22, 1, 133, nil, 0, LanguageTurkish}
+	if actual := client.Analyze("Şişli'de buluşalım"); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %+v, got %+v\n", expected, actual)
+	}
+}
@@ -3,14 +3,16 @@ package ucp
 import (
 	"bytes"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/go-gsm/charset"
 )
 
 func TestSubmit(t *testing.T) {
-	actual := encodeMessage([]byte("01"), "Voyager", "09495696599", "Hello world",
-		alphaNumericMessage, "", 23, 1, 1)
+	actual := encodeMessage([]byte("01"), originator{maskSender("Voyager"), oAdCAlphaNum, OriginatorAlphanumeric}, "09495696599", "Hello world",
+		alphaNumericMessage, "", 23, 1, 1, concatRef8, shift{}, submitParams{})
 	data := struct {
 		actual   []byte
 		expected []byte
@@ -54,17 +56,17 @@ func TestGetDataCodingScheme(t *testing.T) {
 	dcsTestCases := []struct {
 		name     string
 		input    string
-		expected string
+		expected byte
 	}{
 		{
 			"alphanumeric",
 			alphaNumericMessage,
-			dataCodingSchemeASCII,
+			0x00,
 		},
 		{
 			"transparent data",
 			transparentData,
-			dataCodingSchemeUCS2,
+			0x08,
 		},
 	}
 
@@ -124,18 +126,16 @@ func TestGetMessageParts(t *testing.T) {
 			"unicode parts",
 			"👌👀👌👀👌👀👌👀👌👀 good shit go౦ԁ sHit👌 thats ✔ some good👌👌shit right👌👌there👌👌👌 right✔there ✔✔if i do ƽaү so my self 💯 i say so 💯 thats what im talking about right there right there (chorus: ʳᶦᵍʰᵗ ᵗʰᵉʳᵉ) mMMMMᎷМ💯 👌👌 👌НO0ОଠOOOOOОଠଠOoooᵒᵒᵒᵒᵒᵒᵒᵒᵒ👌 👌👌 👌 💯 👌 👀 👀 👀 👌👌Good shit",
 			[]string{
-				"👌👀👌👀👌👀👌👀👌👀 good shit go౦ԁ sHit",
-				"👌 thats ✔ some good👌👌shit right👌👌there👌👌",
-				"👌 right✔there ✔✔if i do ƽaү so my self 💯 i say so ",
-				"💯 thats what im talking about right there right there (chorus",
-				": ʳᶦᵍʰᵗ ᵗʰᵉʳᵉ) mMMMMᎷМ💯 👌👌 👌НO0",
-				"ОଠOOOOOОଠଠOoooᵒᵒᵒᵒᵒᵒᵒᵒᵒ👌 👌👌 ",
+				"👌👀👌👀👌👀👌👀👌👀 good shit go౦ԁ sHit👌 thats ✔ some good👌👌shi",
+				"t right👌👌there👌👌👌 right✔there ✔✔if i do ƽaү so my self 💯 i sa",
+				"y so 💯 thats what im talking about right there right there (chorus",
+				": ʳᶦᵍʰᵗ ᵗʰᵉʳᵉ) mMMMMᎷМ💯 👌👌 👌НO0ОଠOOOOOОଠଠOoooᵒᵒᵒᵒᵒᵒᵒᵒᵒ👌 👌👌 ",
 				"👌 💯 👌 👀 👀 👀 👌👌Good shit"},
 		},
 	}
 
 	for _, testCase := range getMessagePartsTestCases {
-		actual := getMessageParts(testCase.input)
+		actual := getMessageParts(testCase.input, concatRef8)
 		if len(testCase.expected) != len(actual) {
 			t.Errorf("testcase %s: Expected %v, got %v\n", testCase.name, len(testCase.expected), len(actual))
 		}
@@ -147,13 +147,142 @@ func TestGetMessageParts(t *testing.T) {
 	}
 }
 
+func TestAsciiPartsExtensionTable(t *testing.T) {
+	asciiPartsTestCases := []struct {
+		name     string
+		input    string
+		expected []int
+	}{
+		{"80 euro signs fit in one part", strings.Repeat("€", 80), []int{160}},
+		{"81 euro signs", strings.Repeat("€", 81), []int{152, 10}},
+		{"escape sequence at the part boundary", strings.Repeat("a", 152) + "[" + strings.Repeat("b", 10), []int{152, 12}},
+		{"escape sequence just fits", strings.Repeat("a", 151) + "{" + strings.Repeat("b", 10), []int{153, 10}},
+		{"mixed", strings.Repeat("a{b}c[d]e|f~g^h\\€", 20), []int{152, 153, 153, 62}},
+	}
+
+	for _, testCase := range asciiPartsTestCases {
+		actual := getMessageParts(testCase.input, concatRef8)
+		septets := make([]int, len(actual))
+		for i, part := range actual {
+			septets[i] = len(charset.Encode7Bit(part))
+		}
+		if !reflect.DeepEqual(testCase.expected, septets) {
+			t.Errorf("testcase %s: Expected part septets %v, got %v\n", testCase.name, testCase.expected, septets)
+		}
+		if joined := strings.Join(actual, ""); joined != testCase.input {
+			t.Errorf("testcase %s: Expected the parts to join to the message, got %q\n", testCase.name, joined)
+		}
+	}
+}
+
+func TestUcs2Parts(t *testing.T) {
+	ucs2PartsTestCases := []struct {
+		name     string
+		input    string
+		refBits  int
+		expected []int
+	}{
+		{"cyrillic fits in one part", strings.Repeat("Привет", 5), concatRef8, []int{30}},
+		{"70 code units", strings.Repeat("ж", 70), concatRef8, []int{70}},
+		{"71 code units", strings.Repeat("ж", 71), concatRef8, []int{67, 4}},
+		{"71 code units with 16-bit reference", strings.Repeat("ж", 71), concatRef16, []int{66, 5}},
+		{"35 emoji fit in one part", strings.Repeat("😃", 35), concatRef8, []int{70}},
+		{"surrogate pair at the part boundary", strings.Repeat("ж", 66) + strings.Repeat("😃", 3), concatRef8, []int{66, 6}},
+	}
+
+	for _, testCase := range ucs2PartsTestCases {
+		actual := getMessageParts(testCase.input, testCase.refBits)
+		units := make([]int, len(actual))
+		for i, part := range actual {
+			units[i] = ucs2Units(part)
+		}
+		if !reflect.DeepEqual(testCase.expected, units) {
+			t.Errorf("testcase %s: Expected part code units %v, got %v\n", testCase.name, testCase.expected, units)
+		}
+		if joined := strings.Join(actual, ""); joined != testCase.input {
+			t.Errorf("testcase %s: Expected the parts to join to the message, got %q\n", testCase.name, joined)
+		}
+	}
+}
+
+func TestBuildHexMsgUcs2(t *testing.T) {
+	// U+1F603 is sent as the surrogate pair D83D DE03
+	if actual := buildHexMsg(transparentData, "a😃"); actual != "0061D83DDE03" {
+		t.Errorf("Expected %v, got %v\n", "0061D83DDE03", actual)
+	}
+}
+
+func TestGetMessagePartsRefBits(t *testing.T) {
+	getMessagePartsTestCases := []struct {
+		refBits  int
+		expected []int
+	}{
+		{concatRef8, []int{153, 153, 4}},
+		{concatRef16, []int{152, 152, 6}},
+	}
+
+	message := strings.Repeat("a", 310)
+	for _, testCase := range getMessagePartsTestCases {
+		actual := getMessageParts(message, testCase.refBits)
+		lengths := make([]int, len(actual))
+		for i, part := range actual {
+			lengths[i] = len(part)
+		}
+		if !reflect.DeepEqual(testCase.expected, lengths) {
+			t.Errorf("refBits %d: Expected part lengths %v, got %v\n", testCase.refBits, testCase.expected, lengths)
+		}
+	}
+}
+
+func TestEncodeBinary(t *testing.T) {
+	actual := encodeBinary([]byte("01"), originator{maskSender("Voyager"), oAdCAlphaNum, OriginatorAlphanumeric}, "09495696599", []byte{0xCA, 0xFE},
+		UDH{PortAddressing16(2948, 9200)}, 0xF5, "")
+	expected := []byte("\x0201/00125/O/51/09495696599/0ED6773E7C2ECB1B//1//1/////////////4/16/CAFE////////5039//0201F501070605040B8423F0060101070101///0A\x03")
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("Expected %s, got %s\n", expected, actual)
+	}
+}
+
+func TestGetBinaryParts(t *testing.T) {
+	getBinaryPartsTestCases := []struct {
+		name     string
+		length   int
+		udh      UDH
+		refBits  int
+		expected []int
+		err      error
+	}{
+		{"no header", 140, nil, concatRef8, []int{140}, nil},
+		{"port header", 133, UDH{PortAddressing16(2948, 9200)}, concatRef8, []int{133}, nil},
+		{"port header and 8-bit concatenation", 134, UDH{PortAddressing16(2948, 9200)}, concatRef8, []int{128, 6}, nil},
+		{"16-bit concatenation", 300, nil, concatRef16, []int{133, 133, 34}, nil},
+		{"header filling a single part", 5, UDH{{ID: 0x70, Data: make([]byte, 132)}}, concatRef8, []int{5}, nil},
+		{"no room left by the concatenation", 6, UDH{{ID: 0x70, Data: make([]byte, 132)}}, concatRef8, []int{}, ErrUDHTooLong},
+		{"header longer than a part", 10, UDH{{ID: 0x70, Data: make([]byte, 150)}}, concatRef8, []int{}, ErrUDHTooLong},
+	}
+
+	for _, testCase := range getBinaryPartsTestCases {
+		actual, err := getBinaryParts(make([]byte, testCase.length), testCase.udh, testCase.refBits)
+		if err != testCase.err {
+			t.Errorf("testcase %s: Expected error %v, got %v\n", testCase.name, testCase.err, err)
+		}
+		lengths := make([]int, len(actual))
+		for i, part := range actual {
+			lengths[i] = len(part)
+		}
+		if !reflect.DeepEqual(testCase.expected, lengths) {
+			t.Errorf("testcase %s: Expected part lengths %v, got %v\n", testCase.name, testCase.expected, lengths)
+		}
+	}
+}
+
 func BenchmarkEnodeMsg(b *testing.B) {
 	message := "The quick brown fox jumps over the lazy dog is an English-language pangram - a sentence that contains all of the letters of the alphabet."
 	sender := "Voyager"
 	receiver := "09191234567"
 
 	for n := 0; n < b.N; n++ {
-		encodeMessage([]byte("01"), sender, receiver, message, alphaNumericMessage,
-			"", 23, 1, 1)
+		encodeMessage([]byte("01"), originator{maskSender(sender), oAdCAlphaNum, OriginatorAlphanumeric}, receiver, message, alphaNumericMessage,
+			"", 23, 1, 1, concatRef8, shift{}, submitParams{})
 	}
 }
@@ -0,0 +1,177 @@
+package ucp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Direction tells whether a frame was written to or read from the connection.
+type Direction int
+
+const (
+	// Outbound frames are written by the local side of the connection.
+	Outbound Direction = iota
+	// Inbound frames are read from the remote side of the connection.
+	Inbound
+)
+
+func (d Direction) String() string {
+	switch d {
+	case Outbound:
+		return "out"
+	case Inbound:
+		return "in"
+	}
+	return fmt.Sprintf("Direction(%d)", int(d))
+}
+
+// FrameHook is called with every raw UCP frame exchanged with the SMSC.
+// raw includes the STX and ETX delimiters and must not be retained after the call returns.
+type FrameHook func(direction Direction, raw []byte, ts time.Time)
+
+// trace calls the hook, if set, with the frame and the current time.
+func (h FrameHook) trace(direction Direction, raw []byte) {
+	if h != nil {
+		h(direction, raw, time.Now())
+	}
+}
+
+// CapturedFrame is a single frame read back from a capture file.
+type CapturedFrame struct {
+	Direction Direction
+	Raw       []byte
+	Time      time.Time
+}
+
+// Recorder writes frames to a capture file, one frame per line.
+// Its OnFrame method can be used as Options.OnFrame.
+type Recorder struct {
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// NewRecorder returns a Recorder that writes captured frames to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w}
+}
+
+// OnFrame appends the frame to the capture.
+// The first write error is kept and returned by Err, later frames are dropped.
+func (r *Recorder) OnFrame(direction Direction, raw []byte, ts time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	_, r.err = fmt.Fprintf(r.w, "%s %s %q\n", ts.Format(time.RFC3339Nano), direction, raw)
+}
+
+// Err returns the first error encountered while writing the capture.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// ReadCapture parses a capture written by a Recorder.
+func ReadCapture(r io.Reader) ([]CapturedFrame, error) {
+	frames := make([]CapturedFrame, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if text == "" {
+			continue
+		}
+		fields := strings.SplitN(text, " ", 3)
+		if len(fields) != 3 {
+			return frames, fmt.Errorf("capture line %d: malformed record", line)
+		}
+		ts, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return frames, fmt.Errorf("capture line %d: %v", line, err)
+		}
+		var direction Direction
+		switch fields[1] {
+		case Outbound.String():
+			direction = Outbound
+		case Inbound.String():
+			direction = Inbound
+		default:
+			return frames, fmt.Errorf("capture line %d: unknown direction %q", line, fields[1])
+		}
+		raw, err := strconv.Unquote(fields[2])
+		if err != nil {
+			return frames, fmt.Errorf("capture line %d: %v", line, err)
+		}
+		frames = append(frames, CapturedFrame{direction, []byte(raw), ts})
+	}
+	return frames, scanner.Err()
+}
+
+// ReplayError is returned by Replay when a frame received from the peer
+// differs from the one in the capture.
+type ReplayError struct {
+	Index    int
+	Expected []byte
+	Actual   []byte
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("replay: frame %d mismatch, expected %q got %q", e.Index, e.Expected, e.Actual)
+}
+
+// Replayer feeds a capture back into a client or a server.
+//
+// By default the Replayer takes the place of the remote peer of the recording side:
+// frames the recorder read are written to the connection, and frames the recorder
+// wrote are read from the connection and compared with the capture.
+// A capture recorded by a Client can therefore be replayed against a Client
+// to emulate the SMSC. Set AsRecorder to play the recording side instead,
+// e.g. to feed a client capture into a server.
+type Replayer struct {
+	// Frames is the capture to replay.
+	Frames []CapturedFrame
+	// AsRecorder replays the recording side of the capture instead of its peer.
+	AsRecorder bool
+	// Compare reports whether a received frame matches the captured one.
+	// bytes.Equal is used if nil.
+	Compare func(expected, actual []byte) bool
+}
+
+// Replay plays the capture over rw and returns when every frame has been exchanged.
+// Timestamps are not honored; frames are replayed as fast as the peer answers.
+func (r *Replayer) Replay(rw io.ReadWriter) error {
+	compare := r.Compare
+	if compare == nil {
+		compare = bytes.Equal
+	}
+	send := Inbound
+	if r.AsRecorder {
+		send = Outbound
+	}
+	reader := bufio.NewReader(rw)
+	for i, frame := range r.Frames {
+		if frame.Direction == send {
+			if _, err := rw.Write(frame.Raw); err != nil {
+				return err
+			}
+			continue
+		}
+		actual, err := reader.ReadBytes(etx)
+		if err != nil {
+			return err
+		}
+		if !compare(frame.Raw, actual) {
+			return &ReplayError{i, frame.Raw, actual}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package ucp
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecorderReadCapture(t *testing.T) {
+	buf := new(bytes.Buffer)
+	recorder := NewRecorder(buf)
+	ts := time.Date(2018, 11, 2, 9, 11, 32, 123, time.UTC)
+	expected := []CapturedFrame{
+		{Outbound, []byte("\x0200/00061/O/60/emi_client/6/5/1/70617373776F7264//0100//////D1\x03"), ts},
+		{Inbound, []byte("\x0200/00037/R/60/A/BIND AUTHENTICATED/6D\x03"), ts.Add(time.Millisecond)},
+	}
+	for _, frame := range expected {
+		recorder.OnFrame(frame.Direction, frame.Raw, frame.Time)
+	}
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+
+	actual, err := ReadCapture(buf)
+	if err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %v frames, got %v\n", len(expected), len(actual))
+	}
+	for i := range expected {
+		if !actual[i].Time.Equal(expected[i].Time) {
+			t.Errorf("Expected %v, got %v\n", expected[i].Time, actual[i].Time)
+		}
+		actual[i].Time = expected[i].Time
+		if !reflect.DeepEqual(actual[i], expected[i]) {
+			t.Errorf("Expected %+v, got %+v\n", expected[i], actual[i])
+		}
+	}
+}
+
+func TestReadCaptureMalformed(t *testing.T) {
+	_, err := ReadCapture(bytes.NewBufferString("2018-11-02T09:11:32Z sideways \"\\x02\\x03\"\n"))
+	if err == nil {
+		t.Error("Expected error for unknown direction\n")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	loginPacket := login([]byte("00"), "emi_client", "password")
+	loginResp := []byte("\x0200/00037/R/60/A/BIND AUTHENTICATED/6D\x03")
+	replayer := &Replayer{
+		Frames: []CapturedFrame{
+			{Direction: Outbound, Raw: loginPacket},
+			{Direction: Inbound, Raw: loginResp},
+		},
+	}
+	client, server := net.Pipe()
+	defer client.Close()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- replayer.Replay(server)
+		server.Close()
+	}()
+
+	if _, err := client.Write(loginPacket); err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	actual, err := bufio.NewReader(client).ReadBytes(etx)
+	if err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	if !bytes.Equal(loginResp, actual) {
+		t.Errorf("Expected %q, got %q\n", loginResp, actual)
+	}
+	if err := <-errCh; err != nil {
+		t.Errorf("Expected nil error, got %v\n", err)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	replayer := &Replayer{
+		Frames: []CapturedFrame{
+			{Direction: Outbound, Raw: login([]byte("00"), "emi_client", "password")},
+		},
+	}
+	client, server := net.Pipe()
+	defer client.Close()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- replayer.Replay(server)
+		server.Close()
+	}()
+	client.Write(login([]byte("01"), "emi_client", "password"))
+	err := <-errCh
+	if replayErr, ok := err.(*ReplayError); !ok || replayErr.Index != 0 {
+		t.Errorf("Expected *ReplayError at frame 0, got %v\n", err)
+	}
+}
@@ -0,0 +1,235 @@
+package ucp
+
+import (
+	"sync"
+	"time"
+)
+
+// TrackResult is the message level outcome of a tracked message.
+type TrackResult struct {
+	// Key is the correlation key given to Track.
+	Key string
+	// IDs are the message IDs of the parts of the message, as returned by Send.
+	IDs []string
+	// Status is StatusDelivered if every part was delivered, StatusNotDelivered if any part failed
+	// or no part was accepted, and StatusBuffered if the message expired before all parts got a final report.
+	Status DeliveryStatus
+	// Reports holds the latest report of every reported part.
+	Reports []DeliveryReport
+	// Pending lists the IDs of the parts without a final report.
+	Pending []string
+}
+
+// Tracker links delivery reports to submitted messages.
+//
+// Every message sent is recorded with Track under a caller-supplied key.
+// Delivery reports given to Observe are matched to their message by message ID,
+// and once every part of a multipart message has a final report, the message
+// level result is passed to the result callback. Messages that do not get
+// all their final reports within the expiry are passed to the expiry callback.
+//
+// Observe can be used as Options.DeliveryReportHandler.
+type Tracker struct {
+	expiry   time.Duration
+	onResult func(TrackResult)
+	onExpire func(TrackResult)
+
+	// mu guards the fields below
+	mu       sync.Mutex
+	messages map[string]*trackedMessage
+	byID     map[string]*trackedMessage
+	// orphans are reports that arrived before their message was tracked
+	orphans map[string]orphanReport
+	closed  bool
+}
+
+type trackedMessage struct {
+	key     string
+	ids     []string
+	reports map[string]DeliveryReport
+	timer   *time.Timer
+}
+
+type orphanReport struct {
+	report   DeliveryReport
+	received time.Time
+}
+
+// NewTracker returns a Tracker that gives up on messages after expiry.
+// onResult is called when a message got a final report for every part,
+// onExpire when it did not within expiry. Either may be nil.
+// The callbacks are called from the goroutine calling Observe, or from a timer goroutine.
+func NewTracker(expiry time.Duration, onResult, onExpire func(TrackResult)) *Tracker {
+	return &Tracker{
+		expiry:   expiry,
+		onResult: onResult,
+		onExpire: onExpire,
+		messages: make(map[string]*trackedMessage),
+		byID:     make(map[string]*trackedMessage),
+		orphans:  make(map[string]orphanReport),
+	}
+}
+
+// Track records a sent message under key. ids are the message IDs returned by Send,
+// empty IDs of parts that were not accepted are ignored.
+// A message without any accepted part is reported as not delivered right away.
+// Tracking a key again replaces the previous message.
+func (t *Tracker) Track(key string, ids []string) {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+	t.remove(t.messages[key])
+	m := &trackedMessage{
+		key:     key,
+		reports: make(map[string]DeliveryReport),
+	}
+	for _, id := range ids {
+		if id != "" {
+			m.ids = append(m.ids, id)
+		}
+	}
+	t.messages[key] = m
+	for _, id := range m.ids {
+		t.byID[id] = m
+	}
+	m.timer = time.AfterFunc(t.expiry, func() { t.expire(m) })
+
+	// apply the reports that overtook the message
+	t.pruneOrphans()
+	for _, id := range m.ids {
+		if orphan, ok := t.orphans[id]; ok {
+			delete(t.orphans, id)
+			m.reports[id] = orphan.report
+		}
+	}
+	result, done := t.complete(m)
+	t.mu.Unlock()
+	if done && t.onResult != nil {
+		t.onResult(result)
+	}
+}
+
+// Observe matches a delivery report to its tracked message.
+// Reports for messages that are not tracked yet are kept until the expiry,
+// in case Track is called after the report arrived.
+func (t *Tracker) Observe(report DeliveryReport) {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+	m, ok := t.byID[report.MessageID]
+	if !ok {
+		t.pruneOrphans()
+		t.orphans[report.MessageID] = orphanReport{report, time.Now()}
+		t.mu.Unlock()
+		return
+	}
+	// a late buffered report must not replace a final one
+	if previous, ok := m.reports[report.MessageID]; !ok || !previous.Status.Final() || report.Status.Final() {
+		m.reports[report.MessageID] = report
+	}
+	result, done := t.complete(m)
+	t.mu.Unlock()
+	if done && t.onResult != nil {
+		t.onResult(result)
+	}
+}
+
+// Pending returns the number of tracked messages waiting for reports.
+func (t *Tracker) Pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.messages)
+}
+
+// Close stops all expiry timers. Reports observed after Close are ignored.
+func (t *Tracker) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	for _, m := range t.messages {
+		t.remove(m)
+	}
+}
+
+// complete removes m and returns its result if every part has a final report.
+// t.mu must be held.
+func (t *Tracker) complete(m *trackedMessage) (TrackResult, bool) {
+	result := m.result()
+	if len(result.Pending) > 0 {
+		return result, false
+	}
+	t.remove(m)
+	return result, true
+}
+
+// expire reports m as expired if it is still tracked.
+func (t *Tracker) expire(m *trackedMessage) {
+	t.mu.Lock()
+	if t.messages[m.key] != m {
+		t.mu.Unlock()
+		return
+	}
+	t.remove(m)
+	result := m.result()
+	result.Status = StatusBuffered
+	t.mu.Unlock()
+	if t.onExpire != nil {
+		t.onExpire(result)
+	}
+}
+
+// remove stops tracking m. t.mu must be held.
+func (t *Tracker) remove(m *trackedMessage) {
+	if m == nil {
+		return
+	}
+	m.timer.Stop()
+	if t.messages[m.key] == m {
+		delete(t.messages, m.key)
+	}
+	for _, id := range m.ids {
+		if t.byID[id] == m {
+			delete(t.byID, id)
+		}
+	}
+}
+
+// pruneOrphans drops the orphan reports older than the expiry. t.mu must be held.
+func (t *Tracker) pruneOrphans() {
+	for id, orphan := range t.orphans {
+		if time.Since(orphan.received) > t.expiry {
+			delete(t.orphans, id)
+		}
+	}
+}
+
+// result builds the message level result of m from the reports received so far.
+func (m *trackedMessage) result() TrackResult {
+	result := TrackResult{
+		Key:    m.key,
+		IDs:    m.ids,
+		Status: StatusDelivered,
+	}
+	if len(m.ids) == 0 {
+		// no part was accepted, so there is nothing to deliver
+		result.Status = StatusNotDelivered
+	}
+	for _, id := range m.ids {
+		report, ok := m.reports[id]
+		if ok {
+			result.Reports = append(result.Reports, report)
+		}
+		if !ok || !report.Status.Final() {
+			result.Pending = append(result.Pending, id)
+			continue
+		}
+		if report.Status != StatusDelivered {
+			result.Status = StatusNotDelivered
+		}
+	}
+	return result
+}
@@ -0,0 +1,133 @@
+package ucp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	report := func(id string, status DeliveryStatus) DeliveryReport {
+		return DeliveryReport{MessageID: id, Status: status}
+	}
+	testcases := []struct {
+		name     string
+		ids      []string
+		before   []DeliveryReport
+		after    []DeliveryReport
+		expected DeliveryStatus
+		pending  int
+		done     bool
+	}{
+		{
+			name:     "single part delivered",
+			ids:      []string{"a:1"},
+			after:    []DeliveryReport{report("a:1", StatusDelivered)},
+			expected: StatusDelivered,
+			done:     true,
+		},
+		{
+			name:  "buffered is not final",
+			ids:   []string{"a:1"},
+			after: []DeliveryReport{report("a:1", StatusBuffered)},
+		},
+		{
+			name: "multipart waits for every part",
+			ids:  []string{"a:1", "a:2"},
+			after: []DeliveryReport{
+				report("a:2", StatusDelivered),
+			},
+		},
+		{
+			name: "multipart with a failed part",
+			ids:  []string{"a:1", "a:2", "a:3"},
+			after: []DeliveryReport{
+				report("a:1", StatusDelivered),
+				report("a:2", StatusBuffered),
+				report("a:3", StatusDelivered),
+				report("a:2", StatusNotDelivered),
+			},
+			expected: StatusNotDelivered,
+			done:     true,
+		},
+		{
+			name: "late buffered report after final",
+			ids:  []string{"a:1", "a:2"},
+			after: []DeliveryReport{
+				report("a:1", StatusDelivered),
+				report("a:1", StatusBuffered),
+				report("a:2", StatusDelivered),
+			},
+			expected: StatusDelivered,
+			done:     true,
+		},
+		{
+			name:     "report before track",
+			ids:      []string{"a:1"},
+			before:   []DeliveryReport{report("a:1", StatusDelivered)},
+			expected: StatusDelivered,
+			done:     true,
+		},
+		{
+			name:     "rejected parts are skipped",
+			ids:      []string{"a:1", ""},
+			after:    []DeliveryReport{report("a:1", StatusDelivered)},
+			expected: StatusDelivered,
+			done:     true,
+		},
+		{
+			name:     "no accepted part",
+			ids:      []string{"", ""},
+			expected: StatusNotDelivered,
+			done:     true,
+		},
+		{
+			name:     "no ids",
+			expected: StatusNotDelivered,
+			done:     true,
+		},
+	}
+	for _, tc := range testcases {
+		var results []TrackResult
+		tracker := NewTracker(time.Hour, func(r TrackResult) { results = append(results, r) }, nil)
+		for _, r := range tc.before {
+			tracker.Observe(r)
+		}
+		tracker.Track("key", tc.ids)
+		for _, r := range tc.after {
+			tracker.Observe(r)
+		}
+		tracker.Close()
+		if !tc.done {
+			if len(results) != 0 {
+				t.Errorf("%s: expected no result, got %+v", tc.name, results)
+			}
+			continue
+		}
+		if len(results) != 1 {
+			t.Errorf("%s: expected 1 result, got %d", tc.name, len(results))
+			continue
+		}
+		if results[0].Key != "key" || results[0].Status != tc.expected || len(results[0].Pending) != 0 {
+			t.Errorf("%s: expected key with status %v, got %+v", tc.name, tc.expected, results[0])
+		}
+	}
+}
+
+func TestTrackerExpire(t *testing.T) {
+	expired := make(chan TrackResult, 1)
+	tracker := NewTracker(20*time.Millisecond, nil, func(r TrackResult) { expired <- r })
+	defer tracker.Close()
+	tracker.Track("key", []string{"a:1", "a:2"})
+	tracker.Observe(DeliveryReport{MessageID: "a:1", Status: StatusDelivered})
+	select {
+	case r := <-expired:
+		if r.Key != "key" || r.Status != StatusBuffered || len(r.Pending) != 1 || r.Pending[0] != "a:2" {
+			t.Errorf("Unexpected expired result %+v", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the message to expire")
+	}
+	if n := tracker.Pending(); n != 0 {
+		t.Errorf("Expected no pending message, got %d", n)
+	}
+}
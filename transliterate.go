@@ -0,0 +1,85 @@
+package ucp
+
+import (
+	"strings"
+
+	"github.com/go-gsm/charset"
+)
+
+// Substitution is a character replaced by Transliterate.
+type Substitution struct {
+	// Offset is the byte offset of the character in the original message.
+	Offset int
+	// From is the replaced character.
+	From rune
+	// To is its replacement in the GSM 7-bit alphabet, empty if it was removed.
+	To string
+}
+
+// transliterations are the GSM 7-bit replacements of typographic punctuation
+// and of accented letters missing from the default alphabet.
+var transliterations = map[rune]string{
+	// quotes and apostrophes
+	'‘': "'", '’': "'", '‚': "'", '‛': "'", '′': "'", '‹': "'", '›': "'", '`': "'", '´': "'",
+	'“': "\"", '”': "\"", '„': "\"", '‟': "\"", '″': "\"", '«': "\"", '»': "\"",
+	// dashes and other punctuation
+	'‐': "-", '‑': "-", '‒': "-", '–': "-", '—': "-", '―': "-", '−': "-",
+	'…': "...", '•': "*", '·': ".", '™': "TM", '©': "(c)", '®': "(R)",
+	// spaces
+	'\u00A0': " ", '\u2002': " ", '\u2003': " ", '\u2009': " ", '\u200A': " ", '\u202F': " ", '\u200B': "",
+	// accented letters
+	'á': "a", 'â': "a", 'ã': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'Á': "A", 'À': "A", 'Â': "A", 'Ã': "A", 'Ā': "A", 'Ă': "A", 'Ą': "A",
+	'ç': "c", 'ć': "c", 'č': "c", 'Ć': "C", 'Č': "C",
+	'ď': "d", 'đ': "d", 'Ď': "D", 'Đ': "D",
+	'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'È': "E", 'Ê': "E", 'Ë': "E", 'Ē': "E", 'Ė': "E", 'Ę': "E", 'Ě': "E",
+	'ğ': "g", 'Ğ': "G",
+	'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'į': "i", 'ı': "i",
+	'Í': "I", 'Ì': "I", 'Î': "I", 'Ï': "I", 'Ī': "I", 'Į': "I", 'İ': "I",
+	'ł': "l", 'ľ': "l", 'ĺ': "l", 'Ł': "L", 'Ľ': "L", 'Ĺ': "L",
+	'ń': "n", 'ň': "n", 'Ń': "N", 'Ň': "N",
+	'ó': "o", 'ô': "o", 'õ': "o", 'ō': "o", 'ő': "o",
+	'Ó': "O", 'Ò': "O", 'Ô': "O", 'Õ': "O", 'Ō': "O", 'Ő': "O",
+	'œ': "oe", 'Œ': "OE",
+	'ř': "r", 'ŕ': "r", 'Ř': "R", 'Ŕ': "R",
+	'ś': "s", 'š': "s", 'ş': "s", 'ș': "s", 'Ś': "S", 'Š': "S", 'Ş': "S", 'Ș': "S",
+	'ť': "t", 'ţ': "t", 'ț': "t", 'Ť': "T", 'Ţ': "T", 'Ț': "T",
+	'ú': "u", 'û': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'Ú': "U", 'Ù': "U", 'Û': "U", 'Ū': "U", 'Ů': "U", 'Ű': "U", 'Ų': "U",
+	'ý': "y", 'ÿ': "y", 'Ý': "Y", 'Ÿ': "Y",
+	'ź': "z", 'ż': "z", 'ž': "z", 'Ź': "Z", 'Ż': "Z", 'Ž': "Z",
+}
+
+// Transliterate replaces the typographic punctuation and accented letters of a message
+// that are not in the GSM 7-bit default alphabet with their closest GSM 7-bit equivalents.
+// Other characters are kept, so the result may still need UCS-2.
+func Transliterate(message string) (string, []Substitution) {
+	var b strings.Builder
+	substitutions := make([]Substitution, 0)
+	for i, r := range message {
+		if to, ok := transliterations[r]; ok && !charset.IsGsmAlpha(string(r)) {
+			b.WriteString(to)
+			substitutions = append(substitutions, Substitution{Offset: i, From: r, To: to})
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), substitutions
+}
+
+// chooseText splits the message like chooseShift. If transliterate is set, a message that
+// needs UCS-2 is transliterated when that spares it from UCS-2.
+// It returns the message to send and the substitutions made, along with the result of chooseShift.
+func chooseText(message string, transliterate bool, refBits int, languages []Language) (string, string, shift, []string, []Substitution) {
+	msgType, sh, msgParts := chooseShift(message, refBits, languages)
+	if !transliterate || msgType != transparentData {
+		return message, msgType, sh, msgParts, nil
+	}
+	transliterated, subs := Transliterate(message)
+	// keep the original message if it needs UCS-2 anyway
+	if tMsgType, tShift, tParts := chooseShift(transliterated, refBits, languages); tMsgType == alphaNumericMessage {
+		return transliterated, tMsgType, tShift, tParts, subs
+	}
+	return message, msgType, sh, msgParts, nil
+}
@@ -0,0 +1,30 @@
+package ucp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTransliterate(t *testing.T) {
+	transliterateTestCases := []struct {
+		name          string
+		input         string
+		expected      string
+		substitutions []Substitution
+	}{
+		{"gsm", "Hello, Zoë? é", "Hello, Zoe? é", []Substitution{{9, 'ë', "e"}}},
+		{"smart quotes", "“It’s”", "\"It's\"", []Substitution{{0, '“', "\""}, {5, '’', "'"}, {9, '”', "\""}}},
+		{"dash and ellipsis", "a – b…", "a - b...", []Substitution{{2, '–', "-"}, {7, '…', "..."}}},
+		{"zero width space removed", "a\u200Bb", "ab", []Substitution{{1, '\u200B', ""}}},
+		{"unknown characters kept", "Привет’", "Привет'", []Substitution{{12, '’', "'"}}},
+		{"nothing to replace", "hello", "hello", []Substitution{}},
+	}
+
+	for _, testCase := range transliterateTestCases {
+		actual, substitutions := Transliterate(testCase.input)
+		if actual != testCase.expected || !reflect.DeepEqual(testCase.substitutions, substitutions) {
+			t.Errorf("testcase %s: Expected %q %v, got %q %v\n", testCase.name,
+				testCase.expected, testCase.substitutions, actual, substitutions)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package ucptest
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// notification type bits of the NT field
+const (
+	ntDelivered    = 1
+	ntNonDelivered = 2
+	ntBuffered     = 4
+)
+
+// Outcome is the scripted fate of a submitted message.
+type Outcome int
+
+const (
+	// OutcomeDelivered delivers the message after DeliveryRule.Delay.
+	OutcomeDelivered Outcome = iota
+	// OutcomeBufferedThenDelivered buffers the message after DeliveryRule.BufferedDelay
+	// and delivers it after DeliveryRule.Delay.
+	OutcomeBufferedThenDelivered
+	// OutcomeFailed fails the message after DeliveryRule.Delay with DeliveryRule.Reason.
+	OutcomeFailed
+)
+
+// DeliveryRule decides which delivery notifications the server generates for a submission.
+type DeliveryRule struct {
+	// Receiver is the AdC prefix the rule applies to. An empty Receiver matches every AdC.
+	Receiver string
+	// Outcome is what happens to matching messages.
+	Outcome Outcome
+	// Delay is the time between the submission and its final notification.
+	Delay time.Duration
+	// BufferedDelay is the time between the submission and its buffered notification.
+	BufferedDelay time.Duration
+	// Reason is the three digit reason code (Rsn) of buffered and failed notifications.
+	Reason string
+}
+
+// SetDeliveryRules makes the server generate delivery notifications for the
+// submissions that request them with NRq and NT, like a real SMSC.
+// The first rule whose Receiver matches the AdC of a submission applies.
+// Submissions matching no rule get no notification.
+// Notifications are sent on the connection the message was submitted on,
+// with the message ID and SCTS of the acknowledgement.
+func (s *Server) SetDeliveryRules(rules ...DeliveryRule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveryRules = append([]DeliveryRule(nil), rules...)
+}
+
+// deliveryRule returns the rule matching receiver, if any.
+func (s *Server) deliveryRule(receiver string) (DeliveryRule, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rule := range s.deliveryRules {
+		if strings.HasPrefix(receiver, rule.Receiver) {
+			return rule, true
+		}
+	}
+	return DeliveryRule{}, false
+}
+
+// scheduleNotifications schedules the delivery notifications requested by a submission.
+func (s *Server) scheduleNotifications(ss *session, sub Submission) {
+	if sub.Fields[idxNRq] != "1" {
+		return
+	}
+	nt, err := strconv.Atoi(sub.Fields[idxNT])
+	if err != nil {
+		return
+	}
+	rule, ok := s.deliveryRule(sub.Receiver)
+	if !ok {
+		return
+	}
+	n := Notification{MessageID: sub.ID, Originator: sub.Sender}
+	switch rule.Outcome {
+	case OutcomeDelivered:
+		if nt&ntDelivered != 0 {
+			s.notifyAfter(ss, rule.Delay, n)
+		}
+	case OutcomeBufferedThenDelivered:
+		if nt&ntBuffered != 0 {
+			buffered := n
+			buffered.Status = Buffered
+			buffered.Reason = rule.Reason
+			s.notifyAfter(ss, rule.BufferedDelay, buffered)
+		}
+		if nt&ntDelivered != 0 {
+			s.notifyAfter(ss, rule.Delay, n)
+		}
+	case OutcomeFailed:
+		if nt&ntNonDelivered != 0 {
+			n.Status = NotDelivered
+			n.Reason = rule.Reason
+			s.notifyAfter(ss, rule.Delay, n)
+		}
+	}
+}
+
+// notifyAfter sends a delivery notification on the session after delay.
+func (s *Server) notifyAfter(ss *session, delay time.Duration, n Notification) {
+	time.AfterFunc(delay, func() {
+		s.mu.Lock()
+		_, open := s.sessions[ss]
+		s.mu.Unlock()
+		if !open {
+			return
+		}
+		fault := s.currentPushFault()
+		fields := notificationFields(n, time.Now())
+		for i := 0; i <= fault.DuplicateNotifications; i++ {
+			ss.operation(opDeliverNotification, fields, fault.CorruptChecksum)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package ucptest
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gsm/ucp"
+)
+
+func TestDeliveryRules(t *testing.T) {
+	s := NewServer("emi_client", "password")
+	defer s.Close()
+	s.SetDeliveryRules(
+		DeliveryRule{Receiver: "0919000", Outcome: OutcomeFailed, Reason: "108"},
+		DeliveryRule{Receiver: "0919", Outcome: OutcomeDelivered, Delay: 10 * time.Millisecond},
+	)
+	dr := make(chan handled, 2)
+	client := connect(t, s, "password", func(c *ucp.Client) {
+		c.DeliveryHandler(recorder(dr))
+	})
+	defer client.Close()
+
+	ids, err := client.Send("Voyager", "09191234567", "hello")
+	if err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	select {
+	case actual := <-dr:
+		if actual.messageID != ids[0] {
+			t.Errorf("Expected %v, got %v\n", ids[0], actual.messageID)
+		}
+		if actual.sender != "Voyager" || actual.receiver != "09191234567" {
+			t.Errorf("Expected Voyager -> 09191234567, got %v -> %v\n", actual.sender, actual.receiver)
+		}
+		if !strings.Contains(actual.message, "has been delivered") {
+			t.Errorf("Expected delivered text, got %q\n", actual.message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected notification\n")
+	}
+
+	// the client only requests delivered notifications (NT 1)
+	for _, receiver := range []string{"09190001234", "09201234567"} {
+		if _, err := client.Send("Voyager", receiver, "hello"); err != nil {
+			t.Fatalf("Expected nil error, got %v\n", err)
+		}
+	}
+	select {
+	case actual := <-dr:
+		t.Errorf("Expected no notification, got %+v\n", actual)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// rawSubmit logs in over a plain connection and submits a message with the given NT.
+func rawSubmit(t *testing.T, s *Server, receiver, nt string) (*bufio.Reader, net.Conn, string) {
+	conn, err := net.Dial("tcp", s.Addr)
+	if err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	reader := bufio.NewReader(conn)
+	session := []string{"emi_client", "6", "5", "1", hex.EncodeToString([]byte("password")), "", "0100", "", "", "", "", ""}
+	conn.Write(encodeFrame("00", operation, opSessionManagement, session))
+	if f, err := readFrame(reader); err != nil || f.field(0) != positiveAck {
+		t.Fatalf("Expected login, got %+v %v\n", f, err)
+	}
+	fields := make([]string, messageFields)
+	fields[idxAdC] = receiver
+	fields[idxOAdC] = "2371"
+	fields[idxNRq] = "1"
+	fields[idxNT] = nt
+	fields[idxMT] = alphaNumeric
+	fields[idxMsg] = "68656C6C6F"
+	conn.Write(encodeFrame("01", operation, opSubmitShortMessage, fields))
+	f, err := readFrame(reader)
+	if err != nil || f.field(0) != positiveAck {
+		t.Fatalf("Expected ack, got %+v %v\n", f, err)
+	}
+	return reader, conn, f.field(2)
+}
+
+func TestDeliveryRuleOutcomes(t *testing.T) {
+	s := NewServer("emi_client", "password")
+	defer s.Close()
+	s.SetDeliveryRules(
+		DeliveryRule{Receiver: "0919000", Outcome: OutcomeFailed, Reason: "108"},
+		DeliveryRule{Outcome: OutcomeBufferedThenDelivered, BufferedDelay: 10 * time.Millisecond, Delay: 50 * time.Millisecond},
+	)
+	deliveryRuleTestCases := []struct {
+		receiver string
+		nt       string
+		expected []string
+	}{
+		{"09190001234", "7", []string{"2/108"}},
+		{"09191234567", "7", []string{"1/000", "0/000"}},
+		{"09191234567", "1", []string{"0/000"}},
+	}
+	for _, testCase := range deliveryRuleTestCases {
+		reader, conn, id := rawSubmit(t, s, testCase.receiver, testCase.nt)
+		for _, expected := range testCase.expected {
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			f, err := readFrame(reader)
+			if err != nil {
+				t.Fatalf("Expected notification %v for %v, got %v\n", expected, testCase.receiver, err)
+			}
+			if f.op != opDeliverNotification || f.field(idxOAdC)+":"+f.field(idxSCTS) != id {
+				t.Errorf("Expected notification for %v, got %+v\n", id, f)
+			}
+			if actual := f.field(idxDst) + "/" + f.field(idxRsn); actual != expected {
+				t.Errorf("Expected %v, got %v\n", expected, actual)
+			}
+		}
+		conn.Close()
+	}
+}
@@ -0,0 +1,86 @@
+package ucptest
+
+import "time"
+
+// SubmitFault scripts how the server answers the submissions it matches.
+type SubmitFault struct {
+	// Match selects the submissions the fault applies to. A nil Match selects all of them.
+	// Match is called with the server locked and must not call methods of the Server.
+	Match func(Submission) bool
+	// Times limits how many submissions the fault applies to. Zero means no limit.
+	Times int
+	// ErrorCode, if set, negatively acknowledges the submission with ErrorCode and ErrorMessage.
+	ErrorCode    string
+	ErrorMessage string
+	// Delay postpones the response, e.g. past the client timeout.
+	Delay time.Duration
+	// CorruptChecksum sends the response with a wrong checksum.
+	CorruptChecksum bool
+	// Disconnect closes the connection instead of answering.
+	Disconnect bool
+}
+
+// PushFault scripts how the server pushes mobile-originating messages and delivery notifications.
+type PushFault struct {
+	// DuplicateNotifications is the number of extra copies sent of every delivery notification.
+	DuplicateNotifications int
+	// ReverseParts sends the parts of multipart messages in reverse order.
+	ReverseParts bool
+	// CorruptChecksum sends every pushed operation with a wrong checksum.
+	CorruptChecksum bool
+}
+
+// InjectSubmitFault adds a fault to the script of the server.
+// Faults are tried in the order they were injected, the first match applies.
+func (s *Server) InjectSubmitFault(fault SubmitFault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.submitFaults = append(s.submitFaults, &fault)
+}
+
+// SetPushFault sets how the server misbehaves when pushing operations.
+func (s *Server) SetPushFault(fault PushFault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pushFault = fault
+}
+
+// ClearFaults removes all scripted faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.submitFaults = nil
+	s.pushFault = PushFault{}
+}
+
+// submitFault returns the first scripted fault matching sub, or nil.
+func (s *Server) submitFault(sub Submission) *SubmitFault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, fault := range s.submitFaults {
+		if fault.Match != nil && !fault.Match(sub) {
+			continue
+		}
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				s.submitFaults = append(s.submitFaults[:i:i], s.submitFaults[i+1:]...)
+			}
+		}
+		return fault
+	}
+	return nil
+}
+
+// corruptChecksum returns a copy of the frame with its checksum altered.
+func corruptChecksum(b []byte) []byte {
+	corrupted := append([]byte(nil), b...)
+	// the checksum is the two characters before ETX
+	i := len(corrupted) - 2
+	if corrupted[i] == '0' {
+		corrupted[i] = '1'
+	} else {
+		corrupted[i] = '0'
+	}
+	return corrupted
+}
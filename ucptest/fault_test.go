@@ -0,0 +1,154 @@
+package ucptest
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-gsm/ucp"
+)
+
+func TestSubmitFaultNack(t *testing.T) {
+	s := NewServer("emi_client", "password")
+	defer s.Close()
+	s.InjectSubmitFault(SubmitFault{
+		Match:        func(sub Submission) bool { return sub.Receiver == "09190000000" },
+		Times:        1,
+		ErrorCode:    "05",
+		ErrorMessage: "Call barring active",
+	})
+	client := connect(t, s, "password", nil)
+	defer client.Close()
+
+	if _, err := client.Send("Voyager", "09191234567", "hello"); err != nil {
+		t.Errorf("Expected nil error, got %v\n", err)
+	}
+	_, err := client.Send("Voyager", "09190000000", "hello")
+	if ucpErr, ok := err.(*ucp.UcpError); !ok || ucpErr.Code != "05" {
+		t.Errorf("Expected error code 05, got %v\n", err)
+	}
+	// the fault only applied once
+	if _, err := client.Send("Voyager", "09190000000", "hello"); err != nil {
+		t.Errorf("Expected nil error, got %v\n", err)
+	}
+	if len(s.Submissions()) != 2 {
+		t.Errorf("Expected 2 submissions, got %v\n", len(s.Submissions()))
+	}
+}
+
+func TestSubmitFaultDelay(t *testing.T) {
+	s := NewServer("emi_client", "password")
+	defer s.Close()
+	s.InjectSubmitFault(SubmitFault{Delay: 2 * time.Second})
+	client := connect(t, s, "password", nil)
+	defer client.Close()
+
+	_, err := client.Send("Voyager", "09191234567", "hello")
+	if ucpErr, ok := err.(*ucp.UcpError); !ok || ucpErr.Code != "010" {
+		t.Errorf("Expected time-out, got %v\n", err)
+	}
+}
+
+func TestSubmitFaultDisconnectMidMultipart(t *testing.T) {
+	s := NewServer("emi_client", "password")
+	defer s.Close()
+	s.InjectSubmitFault(SubmitFault{
+		Match:      func(sub Submission) bool { return sub.Part == 2 },
+		Disconnect: true,
+	})
+	client := connect(t, s, "password", nil)
+	defer client.Close()
+
+	ids, err := client.Send("Voyager", "09191234567", strings.Repeat("long message ", 30))
+	if err == nil {
+		t.Error("Expected error\n")
+	}
+	if len(ids) != 3 || ids[0] == "" || ids[1] != "" {
+		t.Errorf("Expected only the first part to be acknowledged, got %q\n", ids)
+	}
+}
+
+func TestSubmitFaultCorruptChecksum(t *testing.T) {
+	s := NewServer("emi_client", "password")
+	defer s.Close()
+	s.InjectSubmitFault(SubmitFault{CorruptChecksum: true})
+
+	var mu sync.Mutex
+	var problems []string
+	client := ucp.New(&ucp.Options{
+		Addr:     s.Addr,
+		User:     "emi_client",
+		Password: "password",
+		Timeout:  time.Second,
+		OnFrame: func(direction ucp.Direction, raw []byte, ts time.Time) {
+			pdu, err := ucp.Describe(raw)
+			if direction != ucp.Inbound || err != nil || pdu.OT != "51" {
+				return
+			}
+			mu.Lock()
+			problems = append(problems, pdu.Problems...)
+			mu.Unlock()
+		},
+	})
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	defer client.Close()
+	client.Send("Voyager", "09191234567", "hello")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(problems) != 1 || !strings.HasPrefix(problems[0], "checksum mismatch") {
+		t.Errorf("Expected checksum mismatch, got %v\n", problems)
+	}
+}
+
+func TestPushFaultDuplicateNotifications(t *testing.T) {
+	s := NewServer("emi_client", "password")
+	defer s.Close()
+	s.SetPushFault(PushFault{DuplicateNotifications: 1})
+	dr := make(chan handled, 2)
+	client := connect(t, s, "password", func(c *ucp.Client) {
+		c.DeliveryHandler(recorder(dr))
+	})
+	defer client.Close()
+
+	if err := s.DeliverNotification(Notification{MessageID: "09191234567:181102091132", Originator: "2371"}); err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case actual := <-dr:
+			if actual.messageID != "09191234567:181102091132" {
+				t.Errorf("Expected %v, got %v\n", "09191234567:181102091132", actual.messageID)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected notification %d\n", i+1)
+		}
+	}
+}
+
+func TestPushFaultReverseParts(t *testing.T) {
+	s := NewServer("emi_client", "password")
+	defer s.Close()
+	s.SetPushFault(PushFault{ReverseParts: true})
+	mo := make(chan handled, 1)
+	client := connect(t, s, "password", func(c *ucp.Client) {
+		c.ShortMessageHandler(recorder(mo))
+	})
+	defer client.Close()
+
+	message := strings.Repeat("0123456789", 40)
+	if err := s.DeliverMessage("09191234567", "2371", message); err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	select {
+	case actual := <-mo:
+		if actual.message != message {
+			t.Errorf("Expected %q, got %q\n", message, actual.message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected reassembled message\n")
+	}
+}
@@ -0,0 +1,87 @@
+package ucptest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	stx          = 2
+	etx          = 3
+	delimiter    = "/"
+	operation    = "O"
+	result       = "R"
+	positiveAck  = "A"
+	negativeAck  = "N"
+	headerFields = 4
+	// lenMinusData is the length of a frame minus its data fields, see ucp.pduLenMinusData.
+	lenMinusData = 17
+)
+
+var errMalformedFrame = errors.New("malformed frame")
+
+// frame is a decoded UCP frame.
+type frame struct {
+	trn    string
+	typ    string
+	op     string
+	fields []string
+	// checksumOK is false if the checksum of the frame did not match its content.
+	checksumOK bool
+}
+
+// checksum computes the checksum of a UCP frame body.
+func checksum(b []byte) string {
+	var sum byte
+	for _, c := range b {
+		sum += c
+	}
+	return fmt.Sprintf("%02X", sum)
+}
+
+// encodeFrame builds a frame complete with its length and checksum.
+func encodeFrame(trn, typ, op string, fields []string) []byte {
+	data := strings.Join(fields, delimiter)
+	body := fmt.Sprintf("%s/%05d/%s/%s/%s/", trn, lenMinusData+len(data), typ, op, data)
+	buf := make([]byte, 0, len(body)+4)
+	buf = append(buf, stx)
+	buf = append(buf, body...)
+	buf = append(buf, checksum([]byte(body))...)
+	buf = append(buf, etx)
+	return buf
+}
+
+// readFrame reads and decodes the next frame from r.
+func readFrame(r *bufio.Reader) (*frame, error) {
+	raw, err := r.ReadBytes(etx)
+	if err != nil {
+		return nil, err
+	}
+	if i := bytes.LastIndexByte(raw, stx); i >= 0 {
+		raw = raw[i+1:]
+	}
+	body := string(bytes.TrimSuffix(raw, []byte{etx}))
+	i := strings.LastIndex(body, delimiter)
+	splitFields := strings.Split(body, delimiter)
+	if i < 0 || len(splitFields) < headerFields+1 {
+		return nil, errMalformedFrame
+	}
+	return &frame{
+		trn:        splitFields[0],
+		typ:        splitFields[2],
+		op:         splitFields[3],
+		fields:     splitFields[headerFields : len(splitFields)-1],
+		checksumOK: checksum([]byte(body[:i+1])) == body[i+1:],
+	}, nil
+}
+
+// field returns the data field at index i, or "" if the frame is too short.
+func (f *frame) field(i int) string {
+	if i < len(f.fields) {
+		return f.fields[i]
+	}
+	return ""
+}
@@ -0,0 +1,270 @@
+package ucptest
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/go-gsm/charset"
+)
+
+// indexes of the data fields of the 5x operations
+const (
+	idxAdC          = 0
+	idxOAdC         = 1
+	idxNRq          = 3
+	idxNT           = 5
+	idxSCTS         = 14
+	idxDst          = 15
+	idxRsn          = 16
+	idxDSCTS        = 17
+	idxMT           = 18
+	idxNB           = 19
+	idxMsg          = 20
+	idxOTOA         = 28
+	idxXser         = 30
+	messageFields   = 33
+	sctsLayout      = "020106150405"
+	alphaNumeric    = "3"
+	transparentData = "4"
+	oAdCAlphaNum    = "5039"
+	xserUDH         = "01"
+	xserDCS         = "02"
+	dcsGSM          = "00"
+	dcsUCS2         = "08"
+	gsmMaxSingle    = 160
+	gsmMaxMulti     = 153
+	ucs2MaxSingle   = 70
+	ucs2MaxMulti    = 67
+)
+
+// Submission is a submit short message (operation 51) received by the Server.
+type Submission struct {
+	// ID is the message ID returned in the positive acknowledgement, AdC:SCTS.
+	ID string
+	// Receiver is the AdC of the submission.
+	Receiver string
+	// Sender is the OAdC of the submission, decoded if it is alphanumeric.
+	Sender string
+	// Text is the decoded message, empty for binary messages.
+	Text string
+	// Ref, Part and Parts describe the concatenation UDH, all 0 for single part messages.
+	Ref, Part, Parts int
+	// SCTS is the service centre time stamp returned in the acknowledgement.
+	SCTS string
+	// Fields are the raw data fields of the operation.
+	Fields []string
+}
+
+// Status is the status of a delivery notification (Dst).
+type Status int
+
+const (
+	// Delivered means the message reached the handset.
+	Delivered Status = iota
+	// Buffered means the message is waiting in the SMSC.
+	Buffered
+	// NotDelivered means the message failed permanently.
+	NotDelivered
+)
+
+// Notification is a delivery notification (operation 53) pushed by the Server.
+type Notification struct {
+	// MessageID is the ID of the submission the notification is about, AdC:SCTS.
+	MessageID string
+	// Originator is the AdC of the notification, i.e. the sender of the submission.
+	Originator string
+	// Status is the delivery status.
+	Status Status
+	// Reason is the three digit reason code (Rsn), "000" if empty.
+	Reason string
+	// Text is the notification text. A default text is generated if empty.
+	Text string
+}
+
+// xserEntries splits an Xser field into type and data pairs.
+func xserEntries(xser string) map[string]string {
+	m := make(map[string]string)
+	for len(xser) >= 4 {
+		n, err := strconv.ParseUint(xser[2:4], 16, 8)
+		if err != nil || len(xser) < 4+int(n)*2 {
+			break
+		}
+		m[xser[:2]] = xser[4 : 4+n*2]
+		xser = xser[4+n*2:]
+	}
+	return m
+}
+
+// parseConcat extracts the concatenation information element of a hex UDH.
+func parseConcat(udh string) (ref, part, parts int) {
+	octets, err := hex.DecodeString(udh)
+	if err != nil || len(octets) == 0 {
+		return 0, 0, 0
+	}
+	ies := octets[1:]
+	for len(ies) >= 2 && len(ies) >= 2+int(ies[1]) {
+		iei, data := ies[0], ies[2:2+int(ies[1])]
+		switch {
+		case iei == 0x00 && len(data) == 3:
+			return int(data[0]), int(data[2]), int(data[1])
+		case iei == 0x08 && len(data) == 4:
+			return int(data[0])<<8 | int(data[1]), int(data[3]), int(data[2])
+		}
+		ies = ies[2+len(data):]
+	}
+	return 0, 0, 0
+}
+
+// decodeAddress decodes an alphanumeric OAdC packed by the client.
+func decodeAddress(oadc string) string {
+	octets, err := hex.DecodeString(oadc)
+	if err != nil || len(octets) == 0 {
+		return oadc
+	}
+	septets := charset.Unpack7Bit(octets[1:])
+	if n := int(octets[0]) * 4 / 7; n < len(septets) {
+		septets = septets[:n]
+	}
+	sender, err := charset.Decode7Bit(septets)
+	if err != nil {
+		return oadc
+	}
+	return sender
+}
+
+// newSubmission decodes the fields of a submit short message.
+func newSubmission(fields []string, scts string) Submission {
+	get := func(i int) string {
+		if i < len(fields) {
+			return fields[i]
+		}
+		return ""
+	}
+	sub := Submission{
+		ID:       get(idxAdC) + ":" + scts,
+		Receiver: get(idxAdC),
+		Sender:   get(idxOAdC),
+		SCTS:     scts,
+		Fields:   fields,
+	}
+	if get(idxOTOA) == oAdCAlphaNum {
+		sub.Sender = decodeAddress(sub.Sender)
+	}
+	xser := xserEntries(get(idxXser))
+	if udh, ok := xser[xserUDH]; ok {
+		sub.Ref, sub.Part, sub.Parts = parseConcat(udh)
+	}
+	msg, err := hex.DecodeString(get(idxMsg))
+	if err != nil {
+		return sub
+	}
+	switch {
+	case xser[xserDCS] == dcsUCS2:
+		sub.Text, _ = charset.DecodeUcs2(msg)
+	case get(idxMT) == alphaNumeric:
+		sub.Text, _ = charset.Decode7Bit(msg)
+	}
+	return sub
+}
+
+// moParts splits a mobile-originating message the way a handset would,
+// and returns the hex encoded parts and the data coding scheme.
+func moParts(message string) ([]string, string) {
+	if charset.IsGsmAlpha(message) {
+		parts := splitUnits(message, gsmMaxSingle, gsmMaxMulti, func(r rune) int {
+			return len(charset.Encode7Bit(string(r)))
+		})
+		for i, part := range parts {
+			parts[i] = strings.ToUpper(hex.EncodeToString(charset.Encode7Bit(part)))
+		}
+		return parts, dcsGSM
+	}
+	parts := splitUnits(message, ucs2MaxSingle, ucs2MaxMulti, func(r rune) int {
+		return len(utf16.Encode([]rune{r}))
+	})
+	for i, part := range parts {
+		parts[i] = strings.ToUpper(hex.EncodeToString(charset.EncodeUcs2(part)))
+	}
+	return parts, dcsUCS2
+}
+
+// splitUnits splits message into parts of at most maxMulti units,
+// unless the whole message fits in maxSingle units.
+func splitUnits(message string, maxSingle, maxMulti int, units func(rune) int) []string {
+	total := 0
+	for _, r := range message {
+		total += units(r)
+	}
+	if total <= maxSingle {
+		return []string{message}
+	}
+	parts := make([]string, 0)
+	start, n := 0, 0
+	for i, r := range message {
+		u := units(r)
+		if n+u > maxMulti {
+			parts = append(parts, message[start:i])
+			start, n = i, 0
+		}
+		n += u
+	}
+	return append(parts, message[start:])
+}
+
+// deliverFields builds the data fields of a deliver short message part.
+func deliverFields(sender, receiver, scts, msg, dcs string, ref, part, parts int) []string {
+	fields := make([]string, messageFields)
+	fields[idxAdC] = receiver
+	fields[idxOAdC] = sender
+	fields[idxSCTS] = scts
+	fields[idxMT] = alphaNumeric
+	if dcs == dcsUCS2 {
+		fields[idxMT] = transparentData
+		fields[idxNB] = strconv.Itoa(len(msg) * 4)
+	}
+	fields[idxMsg] = msg
+	xser := ""
+	if parts > 1 {
+		xser = fmt.Sprintf("%s06050003%02X%02X%02X", xserUDH, ref, parts, part)
+	}
+	fields[idxXser] = xser + xserDCS + "01" + dcs
+	return fields
+}
+
+// notificationFields builds the data fields of a delivery notification.
+func notificationFields(n Notification, now time.Time) []string {
+	receiver, scts := n.MessageID, ""
+	if i := strings.LastIndex(n.MessageID, ":"); i >= 0 {
+		receiver, scts = n.MessageID[:i], n.MessageID[i+1:]
+	}
+	reason := n.Reason
+	if reason == "" {
+		reason = "000"
+	}
+	text := n.Text
+	if text == "" {
+		outcome := "has been delivered"
+		switch n.Status {
+		case Buffered:
+			outcome = "has been buffered"
+		case NotDelivered:
+			outcome = "could not be delivered"
+		}
+		text = fmt.Sprintf("Message for %s, with identification %s %s on %s at %s.",
+			receiver, scts, outcome, now.Format("2006-01-02"), now.Format("15:04:05"))
+	}
+	fields := make([]string, messageFields)
+	fields[idxAdC] = n.Originator
+	fields[idxOAdC] = receiver
+	fields[idxSCTS] = scts
+	fields[idxDst] = strconv.Itoa(int(n.Status))
+	fields[idxRsn] = reason
+	fields[idxDSCTS] = now.Format(sctsLayout)
+	fields[idxMT] = alphaNumeric
+	fields[idxMsg] = strings.ToUpper(hex.EncodeToString([]byte(text)))
+	return fields
+}
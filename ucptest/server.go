@@ -0,0 +1,353 @@
+// Package ucptest provides an SMSC simulator for testing UCP clients.
+//
+// A Server listens on the loopback interface, accepts session management
+// logins, acknowledges submitted short messages and alerts, and can push
+// mobile-originating messages and delivery notifications to its clients.
+package ucptest
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// EMI error codes used by the Server.
+const (
+	ErrCodeChecksum          = "01"
+	ErrCodeSyntax            = "02"
+	ErrCodeNotSupported      = "03"
+	ErrCodeNotAllowed        = "04"
+	ErrCodeAuthentication    = "07"
+	opAlert                  = "31"
+	opSubmitShortMessage     = "51"
+	opDeliverShortMessage    = "52"
+	opDeliverNotification    = "53"
+	opSessionManagement      = "60"
+	sessionPasswordIndex     = 4
+	sessionAuthenticatedText = "BIND AUTHENTICATED"
+)
+
+// ErrNoSession is returned when a message is pushed while no client is logged in.
+var ErrNoSession = errors.New("ucptest: no client logged in")
+
+// Server is a simulated SMSC.
+type Server struct {
+	// Addr is the ip:port address the server listens on.
+	Addr string
+
+	user     string
+	password string
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	// mu guards the fields below
+	mu          sync.Mutex
+	sessions    map[*session]struct{}
+	submissions []Submission
+	lastSCTS    time.Time
+	moRef       int
+	closed      bool
+	// submitFaults and pushFault script failures, see fault.go
+	submitFaults []*SubmitFault
+	pushFault    PushFault
+	// deliveryRules drive automatic delivery notifications, see delivery.go
+	deliveryRules []DeliveryRule
+}
+
+// session is a client connection to the server.
+type session struct {
+	conn net.Conn
+	// mu guards writes to conn and the fields below
+	mu       sync.Mutex
+	trn      int
+	loggedIn bool
+}
+
+// NewServer starts a server on a random loopback port that accepts logins
+// with the given credentials. The caller should call Close when finished.
+func NewServer(user, password string) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("ucptest: failed to listen on a port: %v", err))
+	}
+	s := &Server{
+		Addr:     listener.Addr().String(),
+		user:     user,
+		password: password,
+		listener: listener,
+		sessions: make(map[*session]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Close stops the server and closes all client connections.
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.listener.Close()
+	for ss := range s.sessions {
+		ss.conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Submissions returns the short messages submitted so far.
+func (s *Server) Submissions() []Submission {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Submission(nil), s.submissions...)
+}
+
+// DeliverMessage pushes a mobile-originating message (operation 52) from sender
+// to receiver to every logged in client. Long messages are split into
+// concatenated parts the way a handset would.
+func (s *Server) DeliverMessage(sender, receiver, message string) error {
+	parts, dcs := moParts(message)
+	s.mu.Lock()
+	s.moRef = (s.moRef + 1) % 256
+	ref := s.moRef
+	s.mu.Unlock()
+	scts := s.nextSCTS()
+	fault := s.currentPushFault()
+	frames := make([][]string, len(parts))
+	for i, part := range parts {
+		frames[i] = deliverFields(sender, receiver, scts, part, dcs, ref, i+1, len(parts))
+	}
+	if fault.ReverseParts {
+		for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+			frames[i], frames[j] = frames[j], frames[i]
+		}
+	}
+	return s.push(opDeliverShortMessage, frames, fault.CorruptChecksum)
+}
+
+// DeliverNotification pushes a delivery notification (operation 53) to every logged in client.
+func (s *Server) DeliverNotification(n Notification) error {
+	fault := s.currentPushFault()
+	fields := notificationFields(n, time.Now())
+	frames := make([][]string, 1+fault.DuplicateNotifications)
+	for i := range frames {
+		frames[i] = fields
+	}
+	return s.push(opDeliverNotification, frames, fault.CorruptChecksum)
+}
+
+func (s *Server) currentPushFault() PushFault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pushFault
+}
+
+// push writes operations to every logged in client.
+func (s *Server) push(op string, operations [][]string, corrupt bool) error {
+	sessions := s.loggedIn()
+	if len(sessions) == 0 {
+		return ErrNoSession
+	}
+	for _, ss := range sessions {
+		for _, fields := range operations {
+			if err := ss.operation(op, fields, corrupt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loggedIn returns the sessions that completed a login.
+func (s *Server) loggedIn() []*session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions := make([]*session, 0, len(s.sessions))
+	for ss := range s.sessions {
+		ss.mu.Lock()
+		if ss.loggedIn {
+			sessions = append(sessions, ss)
+		}
+		ss.mu.Unlock()
+	}
+	return sessions
+}
+
+// nextSCTS returns a service centre time stamp that is unique for the server,
+// so that AdC:SCTS message IDs never collide.
+func (s *Server) nextSCTS() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().Truncate(time.Second)
+	if !now.After(s.lastSCTS) {
+		now = s.lastSCTS.Add(time.Second)
+	}
+	s.lastSCTS = now
+	return now.Format(sctsLayout)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		ss := &session{conn: conn}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.sessions[ss] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.handle(ss)
+	}
+}
+
+// handle answers the operations sent by a client until the connection is closed.
+func (s *Server) handle(ss *session) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.sessions, ss)
+		s.mu.Unlock()
+		ss.conn.Close()
+	}()
+	reader := bufio.NewReader(ss.conn)
+	for {
+		f, err := readFrame(reader)
+		if err == errMalformedFrame {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if f.typ == result {
+			// acknowledgements of pushed operations
+			continue
+		}
+		if !f.checksumOK {
+			ss.nack(f, ErrCodeChecksum, "Checksum error")
+			continue
+		}
+		switch f.op {
+		case opSessionManagement:
+			s.login(ss, f)
+		case opAlert:
+			ss.respond(f, positiveAck, "")
+		case opSubmitShortMessage:
+			if !ss.isLoggedIn() {
+				ss.nack(f, ErrCodeNotAllowed, "Operation not allowed")
+				continue
+			}
+			s.submit(ss, f)
+		default:
+			ss.nack(f, ErrCodeNotSupported, "Operation not supported by system")
+		}
+	}
+}
+
+// login checks the credentials of a session management operation.
+func (s *Server) login(ss *session, f *frame) {
+	password, _ := hex.DecodeString(f.field(sessionPasswordIndex))
+	if f.field(0) != s.user || string(password) != s.password {
+		ss.nack(f, ErrCodeAuthentication, "Authentication failure")
+		return
+	}
+	ss.mu.Lock()
+	ss.loggedIn = true
+	ss.mu.Unlock()
+	ss.respond(f, positiveAck, sessionAuthenticatedText)
+}
+
+// submit records a submitted short message and acknowledges it with its message ID,
+// unless a scripted fault says otherwise.
+// Only positively acknowledged submissions are recorded.
+func (s *Server) submit(ss *session, f *frame) {
+	if len(f.fields) != messageFields {
+		ss.nack(f, ErrCodeSyntax, "Syntax error")
+		return
+	}
+	sub := newSubmission(f.fields, s.nextSCTS())
+	fault := s.submitFault(sub)
+	if fault == nil {
+		s.record(sub)
+		ss.respond(f, positiveAck, "", sub.ID)
+		s.scheduleNotifications(ss, sub)
+		return
+	}
+	if fault.Disconnect {
+		ss.conn.Close()
+		return
+	}
+	acked := fault.ErrorCode == ""
+	fields := []string{negativeAck, fault.ErrorCode, fault.ErrorMessage}
+	if acked {
+		s.record(sub)
+		fields = []string{positiveAck, "", sub.ID}
+	}
+	resp := encodeFrame(f.trn, result, f.op, fields)
+	if fault.CorruptChecksum {
+		resp = corruptChecksum(resp)
+	}
+	respond := func() {
+		ss.write(resp)
+		if acked {
+			s.scheduleNotifications(ss, sub)
+		}
+	}
+	if fault.Delay > 0 {
+		time.AfterFunc(fault.Delay, respond)
+		return
+	}
+	respond()
+}
+
+func (s *Server) record(sub Submission) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.submissions = append(s.submissions, sub)
+}
+
+func (ss *session) isLoggedIn() bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.loggedIn
+}
+
+// write writes a frame to the client.
+func (ss *session) write(b []byte) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	_, err := ss.conn.Write(b)
+	return err
+}
+
+// respond writes a result for the given operation.
+func (ss *session) respond(f *frame, fields ...string) error {
+	return ss.write(encodeFrame(f.trn, result, f.op, fields))
+}
+
+// nack writes a negative result for the given operation.
+func (ss *session) nack(f *frame, code, msg string) error {
+	return ss.respond(f, negativeAck, code, msg)
+}
+
+// operation writes an operation initiated by the server, with a wrong checksum if corrupt is set.
+func (ss *session) operation(op string, fields []string, corrupt bool) error {
+	ss.mu.Lock()
+	trn := fmt.Sprintf("%02d", ss.trn)
+	ss.trn = (ss.trn + 1) % 100
+	ss.mu.Unlock()
+	b := encodeFrame(trn, operation, op, fields)
+	if corrupt {
+		b = corruptChecksum(b)
+	}
+	return ss.write(b)
+}
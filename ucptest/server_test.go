@@ -0,0 +1,150 @@
+package ucptest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-gsm/ucp"
+)
+
+// handled is a message received by a ucp.Handler.
+type handled struct {
+	sender, receiver, messageID, message string
+}
+
+func recorder(ch chan handled) ucp.Handler {
+	return func(sender, receiver, messageID, message, accessCode string) {
+		ch <- handled{sender, receiver, messageID, message}
+	}
+}
+
+func connect(t *testing.T, s *Server, password string, setup func(*ucp.Client)) *ucp.Client {
+	client := ucp.New(&ucp.Options{
+		Addr:     s.Addr,
+		User:     "emi_client",
+		Password: password,
+		Tps:      100,
+		Timeout:  time.Second,
+	})
+	if setup != nil {
+		setup(client)
+	}
+	if err := client.Connect(); err != nil {
+		client.Close()
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	return client
+}
+
+func TestLoginFailure(t *testing.T) {
+	s := NewServer("emi_client", "password")
+	defer s.Close()
+	client := ucp.New(&ucp.Options{Addr: s.Addr, User: "emi_client", Password: "wrong"})
+	defer client.Close()
+	err := client.Connect()
+	if ucpErr, ok := err.(*ucp.UcpError); !ok || ucpErr.Code != ErrCodeAuthentication {
+		t.Errorf("Expected authentication failure, got %v\n", err)
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	s := NewServer("emi_client", "password")
+	defer s.Close()
+	client := connect(t, s, "password", nil)
+	defer client.Close()
+
+	message := strings.Repeat("Hello world! ", 15)
+	ids, err := client.Send("Voyager", "09191234567", message)
+	if err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	submissions := s.Submissions()
+	if len(ids) != 2 || len(submissions) != 2 {
+		t.Fatalf("Expected 2 parts, got %v ids and %v submissions\n", len(ids), len(submissions))
+	}
+	var text string
+	for i, sub := range submissions {
+		if sub.ID != ids[i] {
+			t.Errorf("Expected %v, got %v\n", ids[i], sub.ID)
+		}
+		if sub.Sender != "Voyager" || sub.Receiver != "09191234567" {
+			t.Errorf("Expected Voyager -> 09191234567, got %v -> %v\n", sub.Sender, sub.Receiver)
+		}
+		if sub.Part != i+1 || sub.Parts != 2 {
+			t.Errorf("Expected part %v/2, got %v/%v\n", i+1, sub.Part, sub.Parts)
+		}
+		text += sub.Text
+	}
+	if text != message {
+		t.Errorf("Expected %q, got %q\n", message, text)
+	}
+	if ids[0] == ids[1] {
+		t.Errorf("Expected unique message IDs, got %v\n", ids)
+	}
+	if err := client.Ping(); err != nil {
+		t.Errorf("Expected nil error, got %v\n", err)
+	}
+}
+
+func TestDeliverMessage(t *testing.T) {
+	s := NewServer("emi_client", "password")
+	defer s.Close()
+	mo := make(chan handled, 1)
+	client := connect(t, s, "password", func(c *ucp.Client) {
+		c.ShortMessageHandler(recorder(mo))
+	})
+	defer client.Close()
+
+	messages := []string{"hello", strings.Repeat("long message ", 20), "héllo 世界"}
+	for _, message := range messages {
+		if err := s.DeliverMessage("09191234567", "2371", message); err != nil {
+			t.Fatalf("Expected nil error, got %v\n", err)
+		}
+		select {
+		case actual := <-mo:
+			if actual.sender != "09191234567" || actual.receiver != "2371" || actual.message != message {
+				t.Errorf("Expected %q, got %+v\n", message, actual)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected mobile-originating message %q\n", message)
+		}
+	}
+}
+
+func TestDeliverNotification(t *testing.T) {
+	s := NewServer("emi_client", "password")
+	defer s.Close()
+	dr := make(chan handled, 1)
+	client := connect(t, s, "password", func(c *ucp.Client) {
+		c.DeliveryHandler(recorder(dr))
+	})
+	defer client.Close()
+
+	ids, err := client.Send("Voyager", "09191234567", "hello")
+	if err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	if err := s.DeliverNotification(Notification{MessageID: ids[0], Originator: "2371"}); err != nil {
+		t.Fatalf("Expected nil error, got %v\n", err)
+	}
+	select {
+	case actual := <-dr:
+		if actual.messageID != ids[0] {
+			t.Errorf("Expected %v, got %v\n", ids[0], actual.messageID)
+		}
+		if !strings.Contains(actual.message, "has been delivered") {
+			t.Errorf("Expected delivered text, got %q\n", actual.message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected delivery notification\n")
+	}
+}
+
+func TestNoSession(t *testing.T) {
+	s := NewServer("emi_client", "password")
+	defer s.Close()
+	if err := s.DeliverMessage("09191234567", "2371", "hello"); err != ErrNoSession {
+		t.Errorf("Expected %v, got %v\n", ErrNoSession, err)
+	}
+}
@@ -0,0 +1,180 @@
+package ucp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Information element identifiers of the user data header (3GPP TS 23.040, 9.2.3.24).
+const (
+	IEIConcat8               byte = 0x00
+	IEISpecialSMSIndication  byte = 0x01
+	IEIPort8                 byte = 0x04
+	IEIPort16                byte = 0x05
+	IEIConcat16              byte = 0x08
+	IEITextFormatting        byte = 0x0A
+	IEIPredefinedSound       byte = 0x0B
+	IEIUserDefinedSound      byte = 0x0C
+	IEIPredefinedAnimation   byte = 0x0D
+	IEILargeAnimation        byte = 0x0E
+	IEISmallAnimation        byte = 0x0F
+	IEILargePicture          byte = 0x10
+	IEISmallPicture          byte = 0x11
+	IEIVariablePicture       byte = 0x12
+	IEIUserPromptIndicator   byte = 0x13
+	IEIExtendedObject        byte = 0x14
+	IEIReusedExtendedObject  byte = 0x15
+	IEICompressionControl    byte = 0x16
+	IEIObjectDistribution    byte = 0x17
+	IEIStandardWVGObject     byte = 0x18
+	IEICharacterSizeWVG      byte = 0x19
+	IEIExtendedObjectRequest byte = 0x1A
+	IEINationalSingleShift   byte = 0x24
+	IEINationalLockingShift  byte = 0x25
+)
+
+// ErrInvalidUDH is returned when a user data header cannot be parsed.
+var ErrInvalidUDH = errors.New("invalid user data header")
+
+// ErrUDHTooLong is returned when a user data header leaves no room for the payload of a message.
+var ErrUDHTooLong = errors.New("user data header too long")
+
+// InformationElement is an element of a user data header.
+type InformationElement struct {
+	// ID is the information element identifier (IEI).
+	ID byte
+	// Data is the information element data, without its length.
+	Data []byte
+}
+
+// UDH is a user data header, the information elements preceding the short message.
+type UDH []InformationElement
+
+// ParseUDH parses a user data header, starting with its length octet (UDHL).
+// Octets after the header are ignored.
+func ParseUDH(b []byte) (UDH, error) {
+	if len(b) == 0 || int(b[0]) > len(b)-1 {
+		return nil, ErrInvalidUDH
+	}
+	ies := b[1 : 1+int(b[0])]
+	udh := make(UDH, 0)
+	for len(ies) > 0 {
+		if len(ies) < 2 || len(ies) < 2+int(ies[1]) {
+			return nil, ErrInvalidUDH
+		}
+		data := make([]byte, ies[1])
+		copy(data, ies[2:])
+		udh = append(udh, InformationElement{ID: ies[0], Data: data})
+		ies = ies[2+int(ies[1]):]
+	}
+	return udh, nil
+}
+
+// Bytes returns the user data header with its length octet, or nil if it has no elements.
+func (h UDH) Bytes() []byte {
+	if len(h) == 0 {
+		return nil
+	}
+	b := make([]byte, 1, h.Len())
+	for _, ie := range h {
+		b = append(b, ie.ID, byte(len(ie.Data)))
+		b = append(b, ie.Data...)
+	}
+	b[0] = byte(len(b) - 1)
+	return b
+}
+
+// Len returns the length of the user data header in octets, including its length octet.
+func (h UDH) Len() int {
+	if len(h) == 0 {
+		return 0
+	}
+	n := 1
+	for _, ie := range h {
+		n += 2 + len(ie.Data)
+	}
+	return n
+}
+
+// Find returns the first information element with the given identifier.
+func (h UDH) Find(id byte) (InformationElement, bool) {
+	for _, ie := range h {
+		if ie.ID == id {
+			return ie, true
+		}
+	}
+	return InformationElement{}, false
+}
+
+// Concat returns the reference, total number of parts and part number
+// of the 8-bit or 16-bit concatenation element of the header.
+func (h UDH) Concat() (ref, parts, part int, ok bool) {
+	for _, ie := range h {
+		switch {
+		case ie.ID == IEIConcat8 && len(ie.Data) == 3:
+			return int(ie.Data[0]), int(ie.Data[1]), int(ie.Data[2]), true
+		case ie.ID == IEIConcat16 && len(ie.Data) == 4:
+			return int(ie.Data[0])<<8 | int(ie.Data[1]), int(ie.Data[2]), int(ie.Data[3]), true
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// Ports returns the destination and source ports of the 8-bit or 16-bit application port addressing element.
+func (h UDH) Ports() (destination, source int, ok bool) {
+	for _, ie := range h {
+		switch {
+		case ie.ID == IEIPort8 && len(ie.Data) == 2:
+			return int(ie.Data[0]), int(ie.Data[1]), true
+		case ie.ID == IEIPort16 && len(ie.Data) == 4:
+			return int(ie.Data[0])<<8 | int(ie.Data[1]), int(ie.Data[2])<<8 | int(ie.Data[3]), true
+		}
+	}
+	return 0, 0, false
+}
+
+// String lists the elements of the header, e.g. "00:6D0501 05:0B8423F0".
+func (h UDH) String() string {
+	ies := make([]string, len(h))
+	for i, ie := range h {
+		ies[i] = fmt.Sprintf("%02X:%X", ie.ID, ie.Data)
+	}
+	return strings.Join(ies, " ")
+}
+
+// Concatenation returns a concatenation element, with an 8-bit reference (IEI 00),
+// or a 16-bit one (IEI 08) if refBits is 16.
+func Concatenation(ref, parts, part, refBits int) InformationElement {
+	if refBits == concatRef16 {
+		return InformationElement{IEIConcat16, []byte{byte(ref >> 8), byte(ref), byte(parts), byte(part)}}
+	}
+	return InformationElement{IEIConcat8, []byte{byte(ref), byte(parts), byte(part)}}
+}
+
+// PortAddressing8 returns an 8-bit application port addressing element (IEI 04).
+func PortAddressing8(destination, source int) InformationElement {
+	return InformationElement{IEIPort8, []byte{byte(destination), byte(source)}}
+}
+
+// PortAddressing16 returns a 16-bit application port addressing element (IEI 05),
+// e.g. destination port 2948 for WAP push.
+func PortAddressing16(destination, source int) InformationElement {
+	return InformationElement{IEIPort16, []byte{byte(destination >> 8), byte(destination), byte(source >> 8), byte(source)}}
+}
+
+// SpecialSMSIndication returns a special SMS message indication element (IEI 01),
+// e.g. indication 0x80 with count 0 clears the voice mail waiting indication.
+func SpecialSMSIndication(indication, count byte) InformationElement {
+	return InformationElement{IEISpecialSMSIndication, []byte{indication, count}}
+}
+
+// NationalSingleShift returns a national language single shift element (IEI 24).
+func NationalSingleShift(language byte) InformationElement {
+	return InformationElement{IEINationalSingleShift, []byte{language}}
+}
+
+// NationalLockingShift returns a national language locking shift element (IEI 25).
+func NationalLockingShift(language byte) InformationElement {
+	return InformationElement{IEINationalLockingShift, []byte{language}}
+}
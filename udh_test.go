@@ -0,0 +1,87 @@
+package ucp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestParseUDH(t *testing.T) {
+	parseUDHTestCases := []struct {
+		name     string
+		input    string
+		expected UDH
+		err      error
+	}{
+		{"8-bit concatenation", "0500036D0501", UDH{{IEIConcat8, []byte{0x6D, 0x05, 0x01}}}, nil},
+		{"16-bit concatenation", "06080412AB0302", UDH{{IEIConcat16, []byte{0x12, 0xAB, 0x03, 0x02}}}, nil},
+		{
+			"port addressing and concatenation",
+			"0B05040B8423F00003070201",
+			UDH{{IEIPort16, []byte{0x0B, 0x84, 0x23, 0xF0}}, {IEIConcat8, []byte{0x07, 0x02, 0x01}}},
+			nil,
+		},
+		{"national language shift", "06240101250101", UDH{{IEINationalSingleShift, []byte{0x01}}, {IEINationalLockingShift, []byte{0x01}}}, nil},
+		{"empty element", "020A00", UDH{{IEITextFormatting, []byte{}}}, nil},
+		{"user data after the header", "0401020000414243", UDH{{IEISpecialSMSIndication, []byte{0x00, 0x00}}}, nil},
+		{"truncated element", "05000304", nil, ErrInvalidUDH},
+		{"element longer than the header", "0300036D0501", nil, ErrInvalidUDH},
+		{"empty", "", nil, ErrInvalidUDH},
+	}
+
+	for _, testCase := range parseUDHTestCases {
+		input, _ := hex.DecodeString(testCase.input)
+		actual, err := ParseUDH(input)
+		if err != testCase.err {
+			t.Errorf("testcase %s: Expected error %v, got %v\n", testCase.name, testCase.err, err)
+			continue
+		}
+		if !reflect.DeepEqual(testCase.expected, actual) {
+			t.Errorf("testcase %s: Expected %v, got %v\n", testCase.name, testCase.expected, actual)
+		}
+	}
+}
+
+func TestUDHBytes(t *testing.T) {
+	udhBytesTestCases := []struct {
+		name     string
+		input    UDH
+		expected string
+	}{
+		{"empty", UDH{}, ""},
+		{"8-bit concatenation", UDH{Concatenation(0x6D, 5, 1, concatRef8)}, "0500036D0501"},
+		{"16-bit concatenation", UDH{Concatenation(0x12AB, 3, 2, concatRef16)}, "06080412AB0302"},
+		{"WAP push", UDH{PortAddressing16(2948, 9200)}, "0605040B8423F0"},
+		{"8-bit ports", UDH{PortAddressing8(245, 0)}, "040402F500"},
+		{"voice mail indication", UDH{SpecialSMSIndication(0x80, 0)}, "0401028000"},
+		{"turkish shift", UDH{NationalSingleShift(1), NationalLockingShift(1)}, "06240101250101"},
+	}
+
+	for _, testCase := range udhBytesTestCases {
+		expected, _ := hex.DecodeString(testCase.expected)
+		actual := testCase.input.Bytes()
+		if !bytes.Equal(expected, actual) {
+			t.Errorf("testcase %s: Expected %X, got %X\n", testCase.name, expected, actual)
+		}
+		if testCase.input.Len() != len(expected) {
+			t.Errorf("testcase %s: Expected length %d, got %d\n", testCase.name, len(expected), testCase.input.Len())
+		}
+	}
+}
+
+func TestUDHConcatAndPorts(t *testing.T) {
+	udh := UDH{PortAddressing16(2948, 9200), Concatenation(7, 2, 1, concatRef8)}
+	if ref, parts, part, ok := udh.Concat(); !ok || ref != 7 || parts != 2 || part != 1 {
+		t.Errorf("Expected part 1 of 2 with reference 7, got %d %d %d %v\n", part, parts, ref, ok)
+	}
+	if destination, source, ok := udh.Ports(); !ok || destination != 2948 || source != 9200 {
+		t.Errorf("Expected ports 2948 and 9200, got %d %d %v\n", destination, source, ok)
+	}
+	if _, _, _, ok := (UDH{SpecialSMSIndication(0x80, 0)}).Concat(); ok {
+		t.Errorf("Expected no concatenation element\n")
+	}
+	if s := udh.String(); s != "05:0B8423F0 00:070201" {
+		t.Errorf("Expected %q, got %q\n", "05:0B8423F0 00:070201", s)
+	}
+}
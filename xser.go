@@ -1,56 +1,178 @@
 package ucp
 
 import (
-	"bytes"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"strconv"
+	"strings"
 )
 
-// buildXSerUDH builds a user data header extra service packet.
-func buildXSerUDH(referenceNum, totalMsgParts, msgPartNum int) string {
-	// only one message part, no need for UDH
-	if totalMsgParts == 1 {
-		return ""
+// XSerType is the type of an extra service of the Xser field (EMI 4.x, 5.1).
+type XSerType byte
+
+// Extra service types, as numbered by the EMI specification.
+const (
+	XSerNotUsed                       XSerType = 0x00
+	XSerGSMUDH                        XSerType = 0x01
+	XSerGSMDCS                        XSerType = 0x02
+	XSerMessageType                   XSerType = 0x03
+	XSerMessageReference              XSerType = 0x04
+	XSerPrivacyIndicator              XSerType = 0x05
+	XSerUrgencyIndicator              XSerType = 0x06
+	XSerAckRequest                    XSerType = 0x07
+	XSerMessageUpdating               XSerType = 0x08
+	XSerCallBackNumber                XSerType = 0x09
+	XSerResponseCode                  XSerType = 0x0A
+	XSerTeleserviceID                 XSerType = 0x0B
+	XSerBillingID                     XSerType = 0x0C
+	XSerSingleShotIndicator           XSerType = 0x0D
+	XSerOriginatorTON                 XSerType = 0x0E
+	XSerOriginatorNPI                 XSerType = 0x0F
+	XSerRecipientTON                  XSerType = 0x10
+	XSerRecipientNPI                  XSerType = 0x11
+	XSerMessageOriginalSubmissionTime XSerType = 0x12
+	XSerDestinationNetworkType        XSerType = 0x13
+)
+
+var xserTypeNames = map[XSerType]string{
+	XSerNotUsed:                       "not used",
+	XSerGSMUDH:                        "GSM UDH",
+	XSerGSMDCS:                        "GSM DCS",
+	XSerMessageType:                   "message type",
+	XSerMessageReference:              "message reference",
+	XSerPrivacyIndicator:              "privacy indicator",
+	XSerUrgencyIndicator:              "urgency indicator",
+	XSerAckRequest:                    "acknowledgement request",
+	XSerMessageUpdating:               "message updating",
+	XSerCallBackNumber:                "call back number",
+	XSerResponseCode:                  "response code",
+	XSerTeleserviceID:                 "teleservice ID",
+	XSerBillingID:                     "billing identifier",
+	XSerSingleShotIndicator:           "single shot indicator",
+	XSerOriginatorTON:                 "originator TON",
+	XSerOriginatorNPI:                 "originator NPI",
+	XSerRecipientTON:                  "recipient TON",
+	XSerRecipientNPI:                  "recipient NPI",
+	XSerMessageOriginalSubmissionTime: "message original submission time",
+	XSerDestinationNetworkType:        "destination network type",
+}
+
+// String returns the EMI name of the type, e.g. "GSM UDH", or "type 1A" for unknown types.
+func (t XSerType) String() string {
+	if name, ok := xserTypeNames[t]; ok {
+		return name
 	}
-	return fmt.Sprintf("%s%02X%02X%02X", concatMsgTLDD, referenceNum, totalMsgParts, msgPartNum)
+	return fmt.Sprintf("type %02X", byte(t))
 }
 
-// buildXSERBillingID builds a billing identifier extra service packet
-func buildXSERBillingID(billingID string) string {
-	billingIDPacketLen := len(billingID)
-	if billingIDPacketLen == 0 {
-		return ""
+// ErrInvalidXSer is returned when an Xser field cannot be parsed.
+var ErrInvalidXSer = errors.New("invalid extra services")
+
+// XSerEntry is an extra service, encoded as TTLLDD: its type, the length of its data and the data.
+type XSerEntry struct {
+	Type XSerType
+	Data []byte
+}
+
+// XSer is the list of extra services of the Xser field, in the order they appear.
+// A type may appear more than once.
+type XSer []XSerEntry
+
+// ParseXSer parses an Xser field. On error, the entries before the invalid one are returned
+// with ErrInvalidXSer.
+func ParseXSer(xser string) (XSer, error) {
+	entries := make(XSer, 0)
+	for rest := xser; rest != ""; {
+		if len(rest) < 4 {
+			return entries, ErrInvalidXSer
+		}
+		header, err := hex.DecodeString(rest[:4])
+		if err != nil {
+			return entries, ErrInvalidXSer
+		}
+		n := int(header[1]) * 2
+		if len(rest) < 4+n {
+			return entries, ErrInvalidXSer
+		}
+		data, err := hex.DecodeString(rest[4 : 4+n])
+		if err != nil {
+			return entries, ErrInvalidXSer
+		}
+		entries = append(entries, XSerEntry{XSerType(header[0]), data})
+		rest = rest[4+n:]
 	}
-	return fmt.Sprintf("%s%02X%02X", billingIDXserKey, billingIDPacketLen, billingID)
+	return entries, nil
 }
 
-// parseXser returns a map of xser identifier to xser data from a given xser packet.
-func parseXser(xser string) map[string]string {
-	m := make(map[string]string)
-	if xser == "" {
-		return m
+// String formats the extra services as an Xser field.
+func (x XSer) String() string {
+	var b strings.Builder
+	for _, e := range x {
+		fmt.Fprintf(&b, "%02X%02X%X", byte(e.Type), len(e.Data), e.Data)
 	}
-	buf := bytes.NewBufferString(xser)
-	for buf.Len() > 0 {
-		xserType := buf.Next(2)
-		xserLen := buf.Next(2)
-		convXserlen, err := strconv.ParseInt(string(xserLen), 16, 0)
-		if err != nil {
-			// return an empty map indicating that an xser key-value pair cant be extracted from the string
-			return m
+	return b.String()
+}
+
+// Find returns the first extra service of the given type.
+func (x XSer) Find(t XSerType) (XSerEntry, bool) {
+	for _, e := range x {
+		if e.Type == t {
+			return e, true
 		}
-		xserData := buf.Next(int(convXserlen) * 2)
-		m[string(xserType)] = string(xserData)
 	}
-	return m
+	return XSerEntry{}, false
+}
+
+// UDH returns the user data header of the GSM UDH extra service, if any.
+// The data of the extra service starts with the length of the header.
+func (x XSer) UDH() (UDH, bool, error) {
+	e, ok := x.Find(XSerGSMUDH)
+	if !ok {
+		return nil, false, nil
+	}
+	udh, err := ParseUDH(e.Data)
+	return udh, true, err
+}
+
+// dataCoding returns the data coding scheme of the GSM DCS extra service as a hex string, e.g. "08",
+// or "" if there is none.
+func (x XSer) dataCoding() string {
+	if e, ok := x.Find(XSerGSMDCS); ok && len(e.Data) == 1 {
+		return fmt.Sprintf("%02X", e.Data[0])
+	}
+	return ""
+}
+
+// textUDH returns the user data header of a part of a text message.
+// refBits selects the 8-bit (IEI 00) or 16-bit (IEI 08) concatenation reference.
+// ies, e.g. national language shifts, precede the concatenation element.
+func textUDH(referenceNum, totalMsgParts, msgPartNum, refBits int, ies ...InformationElement) UDH {
+	udh := UDH(ies)
+	// only one message part, no need for a concatenation element
+	if totalMsgParts > 1 {
+		udh = append(udh, Concatenation(referenceNum, totalMsgParts, msgPartNum, refBits))
+	}
+	return udh
 }
 
 // buildXser builds all the required extra services together.
-func buildXser(billingID, messageType string, referenceNum, totalMsgParts, msgPartNum int) string {
-	xserData := getDataCodingScheme(messageType) +
-		buildXSerUDH(referenceNum, totalMsgParts, msgPartNum) +
-		buildXSERBillingID(billingID) +
-		urgencyIndicatorNormal +
-		ackReqDeliveryAck
-	return xserData
+func buildXser(billingID string, dcs byte, udh UDH, urgency Urgency, ackRequest AckRequest) XSer {
+	xser := XSer{{XSerGSMDCS, []byte{dcs}}}
+	if len(udh) > 0 {
+		xser = append(xser, XSerEntry{XSerGSMUDH, udh.Bytes()})
+	}
+	if billingID != "" {
+		xser = append(xser, XSerEntry{XSerBillingID, []byte(billingID)})
+	}
+	return append(xser,
+		XSerEntry{XSerUrgencyIndicator, []byte{urgencyXser[urgency]}},
+		XSerEntry{XSerAckRequest, []byte{ackRequestXser[ackRequest]}})
+}
+
+// xserField returns the Xser field of the fields of an incoming operation, if any.
+func xserField(fields []string) string {
+	if len(fields) <= xserIndex {
+		return ""
+	}
+	return fields[xserIndex]
 }
@@ -5,50 +5,54 @@ import (
 	"testing"
 )
 
-func TestBuildXserUDH(t *testing.T) {
-	xserUdhTestCases := []struct {
+func TestTextUDH(t *testing.T) {
+	textUDHTestCases := []struct {
+		name          string
 		refNum        int
 		totalMsgParts int
 		msgPartNum    int
+		refBits       int
+		ies           []InformationElement
 		expected      string
 	}{
-		{
-			42,
-			1,
-			1,
-			"",
-		},
-		{
-			42,
-			2,
-			1,
-			"01060500032A0201",
-		},
+		{"single part", 42, 1, 1, concatRef8, nil, ""},
+		{"8-bit reference", 42, 2, 1, concatRef8, nil, "01060500032A0201"},
+		{"16-bit reference", 0x12AB, 3, 2, concatRef16, nil, "010706080412AB0302"},
+		{"single part with a shift", 42, 1, 1, concatRef8, []InformationElement{NationalSingleShift(1)}, "010403240101"},
+		{"shift before concatenation", 42, 2, 2, concatRef8, []InformationElement{NationalSingleShift(1)}, "01090824010100032A0202"},
 	}
 
-	for _, testCase := range xserUdhTestCases {
-		actual := buildXSerUDH(testCase.refNum, testCase.totalMsgParts, testCase.msgPartNum)
+	for _, testCase := range textUDHTestCases {
+		udh := textUDH(testCase.refNum, testCase.totalMsgParts, testCase.msgPartNum, testCase.refBits, testCase.ies...)
+		actual := ""
+		if len(udh) > 0 {
+			actual = XSer{{XSerGSMUDH, udh.Bytes()}}.String()
+		}
 		if actual != testCase.expected {
-			t.Errorf("Expected %s, got %s\n", testCase.expected, actual)
+			t.Errorf("testcase %s: Expected %s, got %s\n", testCase.name, testCase.expected, actual)
 		}
 	}
 }
 
-func TestBuildXserBillingID(t *testing.T) {
-
-	billingIDTests := []struct {
-		input    string
-		expected string
+func TestBuildXser(t *testing.T) {
+	buildXserTestCases := []struct {
+		name       string
+		billingID  string
+		dcs        byte
+		udh        UDH
+		urgency    Urgency
+		ackRequest AckRequest
+		expected   string
 	}{
-		{"", ""},
-		{"01000001C123000210", "0C12303130303030303143313233303030323130"},
-		{"01000001C1230001F0", "0C12303130303030303143313233303030314630"},
+		{"defaults", "", dcsGSM7, nil, UrgencyNormal, AckDelivery, "020100060101070101"},
+		{"billing identifier", "01000001C123000210", dcsGSM7, nil, UrgencyNormal, AckDelivery, "0201000C12303130303030303143313233303030323130060101070101"},
+		{"ucs2 with udh", "", dcsUCS2, UDH{Concatenation(42, 2, 1, concatRef8)}, UrgencyBulk, AckNone, "02010801060500032A0201060100070100"},
 	}
 
-	for _, testCase := range billingIDTests {
-		actual := buildXSERBillingID(testCase.input)
+	for _, testCase := range buildXserTestCases {
+		actual := buildXser(testCase.billingID, testCase.dcs, testCase.udh, testCase.urgency, testCase.ackRequest).String()
 		if actual != testCase.expected {
-			t.Errorf("Expected %s, got %s\n", testCase.expected, actual)
+			t.Errorf("testcase %s: Expected %s, got %s\n", testCase.name, testCase.expected, actual)
 		}
 	}
 }
@@ -57,26 +61,68 @@ func TestParseXser(t *testing.T) {
 	parseXserTestCases := []struct {
 		name     string
 		input    string
-		expected map[string]string
+		expected XSer
+		err      error
 	}{
 		{
 			"empty extra service data",
 			"",
-			map[string]string{},
+			XSer{},
+			nil,
 		},
 		{
 			"gsm data coding scheme info",
 			"020100",
-			map[string]string{
-				"02": "00",
-			},
+			XSer{{XSerGSMDCS, []byte{0x00}}},
+			nil,
+		},
+		{
+			"order and duplicates are kept",
+			"0C0141060101020108060102",
+			XSer{{XSerBillingID, []byte("A")}, {XSerUrgencyIndicator, []byte{0x01}}, {XSerGSMDCS, []byte{0x08}}, {XSerUrgencyIndicator, []byte{0x02}}},
+			nil,
+		},
+		{
+			"unknown type and empty data",
+			"1A00",
+			XSer{{XSerType(0x1A), []byte{}}},
+			nil,
+		},
+		{
+			"bad length",
+			"0201000603",
+			XSer{{XSerGSMDCS, []byte{0x00}}},
+			ErrInvalidXSer,
+		},
+		{
+			"truncated entry",
+			"02010006",
+			XSer{{XSerGSMDCS, []byte{0x00}}},
+			ErrInvalidXSer,
+		},
+		{
+			"invalid hex",
+			"02010006010Z",
+			XSer{{XSerGSMDCS, []byte{0x00}}},
+			ErrInvalidXSer,
 		},
 	}
 
 	for _, testCase := range parseXserTestCases {
-		actual := parseXser(testCase.input)
-		if !reflect.DeepEqual(actual, testCase.expected) {
-			t.Errorf("Expected %v, got %v\n", testCase.expected, actual)
+		actual, err := ParseXSer(testCase.input)
+		if !reflect.DeepEqual(actual, testCase.expected) || err != testCase.err {
+			t.Errorf("testcase %s: Expected %v %v, got %v %v\n", testCase.name, testCase.expected, testCase.err, actual, err)
+		}
+		if err == nil && actual.String() != testCase.input {
+			t.Errorf("testcase %s: Expected %s, got %s\n", testCase.name, testCase.input, actual.String())
+		}
+	}
+}
+
+func TestXSerTypeString(t *testing.T) {
+	for xserType, expected := range map[XSerType]string{XSerGSMUDH: "GSM UDH", XSerMessageType: "message type", XSerMessageReference: "message reference", XSerPrivacyIndicator: "privacy indicator", XSerDestinationNetworkType: "destination network type", XSerType(0x1A): "type 1A"} {
+		if actual := xserType.String(); actual != expected {
+			t.Errorf("Expected %s, got %s\n", expected, actual)
 		}
 	}
 }